@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// PayloadSchema maps a payload field name to its Avro primitive type
+// ("string", "long", "int", "boolean", "bytes", ...), so UnwrapAvroValue
+// knows which union branch to expect without re-deriving it from the JSON
+// every time. A zero-value PayloadSchema still works: UnwrapAvroValue falls
+// back to whichever single key is present in the wrapper object.
+type PayloadSchema map[string]string
+
+// LoadPayloadSchema reads an Avro .avsc record schema and extracts each
+// field's primitive type, unwrapping the ["null", "<type>"] union form
+// Avro uses for nullable fields.
+func LoadPayloadSchema(path string) (PayloadSchema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var avsc struct {
+		Fields []struct {
+			Name string          `json:"name"`
+			Type json.RawMessage `json:"type"`
+		} `json:"fields"`
+	}
+	if err := json.Unmarshal(data, &avsc); err != nil {
+		return nil, fmt.Errorf("parsing avro schema %s: %w", path, err)
+	}
+
+	schema := make(PayloadSchema, len(avsc.Fields))
+	for _, f := range avsc.Fields {
+		if t, ok := primaryAvroType(f.Type); ok {
+			schema[f.Name] = t
+		}
+	}
+	return schema, nil
+}
+
+// primaryAvroType extracts the non-null branch of an Avro field type. It
+// understands a bare type name and a ["null", "<type>"] union; anything
+// else (records, arrays, maps) isn't a primitive this tool diffs, so it's
+// reported as not-ok rather than guessed at.
+func primaryAvroType(raw json.RawMessage) (string, bool) {
+	trimmed := strings.TrimSpace(string(raw))
+	if strings.HasPrefix(trimmed, `"`) {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return "", false
+		}
+		return s, true
+	}
+	if strings.HasPrefix(trimmed, "[") {
+		var branches []json.RawMessage
+		if err := json.Unmarshal(raw, &branches); err != nil {
+			return "", false
+		}
+		for _, b := range branches {
+			var s string
+			if err := json.Unmarshal(b, &s); err == nil && s != "null" {
+				return s, true
+			}
+		}
+	}
+	return "", false
+}
+
+// detectPayloadSchemaFromFile auto-detects field types by peeking at the
+// first maxRecords lines of an Avro JSON file when no --avro-schema was
+// supplied, inferring each field's type from whichever union key its
+// wrapper object uses (e.g. {"string": ...} implies "string").
+func detectPayloadSchemaFromFile(filename string, maxRecords int) (PayloadSchema, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	schema := make(PayloadSchema)
+	decoder := json.NewDecoder(f)
+	for i := 0; i < maxRecords; i++ {
+		var rec AvroRecord
+		if err := decoder.Decode(&rec); err != nil {
+			break
+		}
+		for field, raw := range rec.Payload {
+			if _, known := schema[field]; known {
+				continue
+			}
+			var wrapper map[string]json.RawMessage
+			if err := json.Unmarshal(raw, &wrapper); err != nil || len(wrapper) != 1 {
+				continue
+			}
+			for t := range wrapper {
+				schema[field] = t
+			}
+		}
+	}
+	return schema, nil
+}
+
+// UnwrapAvroValue decodes one Avro-union-wrapped payload value into a plain
+// Go value. It handles the {"string": ...}, {"long": ...}, {"int": ...},
+// {"boolean": ...}, {"bytes": ...} forms plus JSON null (the union-null
+// case). avroType picks which wrapper key to read when raw could plausibly
+// contain more than one (it shouldn't, in valid Avro JSON encoding); when
+// avroType is unknown or doesn't match, the wrapper's single key is used
+// instead.
+func UnwrapAvroValue(raw json.RawMessage, avroType string) (interface{}, error) {
+	trimmed := strings.TrimSpace(string(raw))
+	if trimmed == "" || trimmed == "null" {
+		return nil, nil
+	}
+
+	var wrapper map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &wrapper); err != nil {
+		// Not a wrapped union at all (e.g. a bare scalar); decode as-is.
+		var v interface{}
+		if err2 := json.Unmarshal(raw, &v); err2 != nil {
+			return nil, fmt.Errorf("decoding avro value %s: %w", raw, err)
+		}
+		return v, nil
+	}
+
+	inner, ok := wrapper[avroType]
+	if !ok {
+		for k, v := range wrapper {
+			avroType, inner = k, v
+			break
+		}
+	}
+	if inner == nil {
+		return nil, fmt.Errorf("avro union %s has no branches", raw)
+	}
+
+	switch avroType {
+	case "string", "bytes":
+		var s string
+		err := json.Unmarshal(inner, &s)
+		return s, err
+	case "long":
+		var n int64
+		err := json.Unmarshal(inner, &n)
+		return n, err
+	case "int":
+		var n int
+		err := json.Unmarshal(inner, &n)
+		return n, err
+	case "boolean":
+		var b bool
+		err := json.Unmarshal(inner, &b)
+		return b, err
+	case "float", "double":
+		var f float64
+		err := json.Unmarshal(inner, &f)
+		return f, err
+	default:
+		var v interface{}
+		err := json.Unmarshal(inner, &v)
+		return v, err
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// TestUnwrapAvroValue covers each primitive union wrapper form UnwrapAvroValue
+// understands, plus the null and bare-scalar edge cases.
+func TestUnwrapAvroValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		raw      string
+		avroType string
+		want     interface{}
+	}{
+		{name: "string", raw: `{"string":"hello"}`, avroType: "string", want: "hello"},
+		{name: "long", raw: `{"long":42}`, avroType: "long", want: int64(42)},
+		{name: "int", raw: `{"int":7}`, avroType: "int", want: 7},
+		{name: "boolean", raw: `{"boolean":true}`, avroType: "boolean", want: true},
+		{name: "double", raw: `{"double":1.5}`, avroType: "double", want: 1.5},
+		{name: "null", raw: `null`, avroType: "string", want: nil},
+		{name: "unknown avroType falls back to the wrapper's own key", raw: `{"long":9}`, avroType: "string", want: int64(9)},
+		{name: "bare scalar with no union wrapper", raw: `123`, avroType: "long", want: float64(123)},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := UnwrapAvroValue(json.RawMessage(tc.raw), tc.avroType)
+			if err != nil {
+				t.Fatalf("UnwrapAvroValue: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("got %v (%T), want %v (%T)", got, got, tc.want, tc.want)
+			}
+		})
+	}
+}
+
+// TestPrimaryAvroType covers a bare type name and the ["null", "<type>"]
+// nullable-field union form LoadPayloadSchema needs to unwrap.
+func TestPrimaryAvroType(t *testing.T) {
+	if got, ok := primaryAvroType(json.RawMessage(`"string"`)); !ok || got != "string" {
+		t.Errorf("bare type: got (%q, %v), want (\"string\", true)", got, ok)
+	}
+	if got, ok := primaryAvroType(json.RawMessage(`["null", "long"]`)); !ok || got != "long" {
+		t.Errorf("nullable union: got (%q, %v), want (\"long\", true)", got, ok)
+	}
+	if _, ok := primaryAvroType(json.RawMessage(`{"type":"record"}`)); ok {
+		t.Error("expected a record type to report not-ok")
+	}
+}
@@ -4,12 +4,15 @@ import (
 	"bufio"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 	"time"
+
+	"binlog-avro-comparator/pkg/binlogsrc"
 )
 
-// BinlogEvent represents the relevant fields from json_parser.go output for DML/XID events
+// BinlogEvent represents the relevant fields from cmd/jsonparser/main.go output for DML/XID events
 type BinlogEvent struct {
 	EventType                 string `json:"event_type"`
 	Timestamp                 string `json:"timestamp"` // RFC3339 or RFC3339Nano (from 'Date' field)
@@ -17,8 +20,14 @@ type BinlogEvent struct {
 	LogPosition               int64  `json:"log_position"`                // Position at the *end* of the event
 	Table                     string `json:"table"`                       // For DML events
 	Schema                    string `json:"schema"`                      // For DML events
-	BinlogFile                string `json:"binlog_file"`                 // IMPORTANT: Added by modified json_parser.go
+	BinlogFile                string `json:"binlog_file"`                 // IMPORTANT: Added by modified cmd/jsonparser/main.go
 	GTIDNext                  string `json:"gtid_next"`                   // For GTID events
+
+	// Row and BeforeRow are only populated when --native-binlog decoded the
+	// event via pkg/binlogsrc with a schema registered (--schema); the text
+	// parser has no way to recover row payloads, so these stay nil on that path.
+	Row       map[string]interface{} `json:"-"`
+	BeforeRow map[string]interface{} `json:"-"`
 }
 
 // AvroRecord represents a row from your AVRO JSON output
@@ -54,13 +63,11 @@ type AvroRecord struct {
 		IsDeleted                  AvroBoolean `json:"is_deleted"`
 		PrimaryKeys                []string    `json:"primary_keys"` // This appears to be a direct string array
 	} `json:"source_metadata"`
-	Payload struct {
-		OrderID        AvroInt    `json:"order_id"`
-		CustomerName   AvroString `json:"customer_name"`
-		ProductName    AvroString `json:"product_name"`
-		Quantity       AvroInt    `json:"quantity"`
-		OrderTimestamp AvroLong   `json:"order_timestamp"`
-	} `json:"payload"`
+	// Payload is schema-agnostic: each field is still wrapped Avro-union-style
+	// (e.g. {"string": "value"}), but the set of fields depends on whichever
+	// table produced the record. Use payloadSchema (see avro_schema.go) to
+	// unwrap a field into its native Go value.
+	Payload map[string]json.RawMessage `json:"payload"`
 }
 
 // BinlogKey defines the unique identifier for a binlog event or Avro record
@@ -72,14 +79,92 @@ type BinlogKey struct {
 // Maps to store parsed events for efficient lookup
 var binlogEvents = make(map[BinlogKey]BinlogEvent) // Stores all relevant binlog events
 
+// nativeBinlog, when set via --native-binlog, makes loadBinlogData read the
+// first positional argument as a raw binlog file through pkg/binlogsrc
+// instead of the JSON emitted by cmd/jsonparser/main.go.
+var nativeBinlog bool
+
+// nativeSchema, set via --schema <path>, tells the native binlog backend how
+// to name decoded row columns. Payload comparison is skipped without it.
+var nativeSchema binlogsrc.Schema
+
+// nativeRawMode, set via --raw-binlog, skips body decoding for everything
+// but FORMAT_DESCRIPTION and ROTATE when reading a file with --native-binlog.
+// Useful for scanning a binlog quickly (e.g. to locate a GTID's position)
+// without paying for row/GTID/table-map decoding.
+var nativeRawMode bool
+
+// payloadSchema, set via --avro-schema <path>, names the Avro type of each
+// payload field so UnwrapAvroValue doesn't have to guess. Left nil to
+// auto-detect from the first records in the Avro file (see
+// detectPayloadSchemaFromFile).
+var payloadSchema PayloadSchema
+
 func main() {
-	if len(os.Args) != 3 {
-		fmt.Fprintf(os.Stderr, "Usage: %s <binlog_metadata.json> <avro_rows.json>\n", os.Args[0])
+	if len(os.Args) > 1 && os.Args[1] == "stream" {
+		if err := runStreamMode(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error in stream mode: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	args := os.Args[1:]
+	for i := 0; i < len(args); i++ {
+		if consumed, err := parseFilterFlag(args, i); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		} else if consumed > 0 {
+			args = append(args[:i], args[i+consumed:]...)
+			i--
+			continue
+		}
+
+		switch args[i] {
+		case "--native-binlog":
+			nativeBinlog = true
+			args = append(args[:i], args[i+1:]...)
+			i--
+		case "--raw-binlog":
+			nativeRawMode = true
+			args = append(args[:i], args[i+1:]...)
+			i--
+		case "--schema":
+			if i+1 >= len(args) {
+				fmt.Fprintln(os.Stderr, "Error: --schema requires a path argument")
+				os.Exit(1)
+			}
+			s, err := binlogsrc.LoadSchema(args[i+1])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error loading --schema %s: %v\n", args[i+1], err)
+				os.Exit(1)
+			}
+			nativeSchema = s
+			args = append(args[:i], args[i+2:]...)
+			i--
+		case "--avro-schema":
+			if i+1 >= len(args) {
+				fmt.Fprintln(os.Stderr, "Error: --avro-schema requires a path argument")
+				os.Exit(1)
+			}
+			s, err := LoadPayloadSchema(args[i+1])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error loading --avro-schema %s: %v\n", args[i+1], err)
+				os.Exit(1)
+			}
+			payloadSchema = s
+			args = append(args[:i], args[i+2:]...)
+			i--
+		}
+	}
+
+	if len(args) != 2 {
+		fmt.Fprintf(os.Stderr, "Usage: %s [--native-binlog] [--raw-binlog] [--schema <path>] [--avro-schema <path>] [filter flags] <binlog_metadata.json|binlog_file> <avro_rows.json>\n", os.Args[0])
 		os.Exit(1)
 	}
 
-	binlogJSONPath := os.Args[1]
-	avroJSONPath := os.Args[2]
+	binlogJSONPath := args[0]
+	avroJSONPath := args[1]
 
 	fmt.Printf("Loading binlog data from %s...\n", binlogJSONPath)
 	if err := loadBinlogData(binlogJSONPath); err != nil {
@@ -99,6 +184,10 @@ func main() {
 
 // loadBinlogData reads the binlog_metadata.json file and populates the binlogEvents map
 func loadBinlogData(filename string) error {
+	if nativeBinlog {
+		return loadBinlogDataNative(filename)
+	}
+
 	f, err := os.Open(filename)
 	if err != nil {
 		return err
@@ -107,6 +196,8 @@ func loadBinlogData(filename string) error {
 
 	scanner := bufio.NewScanner(f)
 	lineNum := 0
+	var currentTx *Transaction
+	var txExcluded bool
 	for scanner.Scan() {
 		lineNum++
 		var event map[string]interface{}
@@ -121,12 +212,24 @@ func loadBinlogData(filename string) error {
 			continue // Skip if event_type is missing or not a string
 		}
 
+		if eventType == "GTID" {
+			gtidNext, _ := event["gtid_next"].(string)
+			logPos, _ := event["log_position"].(float64)
+			currentTx = &Transaction{GTID: gtidNext, StartPos: int64(logPos)}
+			txExcluded = !gtidPasses(gtidNext)
+			continue
+		}
+
 		isRelevantEventType := strings.HasSuffix(eventType, "RowsEventV2") || eventType == "XID"
 
 		if !isRelevantEventType {
 			continue
 		}
 
+		if txExcluded {
+			continue
+		}
+
 		var binlogEvt BinlogEvent
 		jsonBytes, _ := json.Marshal(event)
 		if err := json.Unmarshal(jsonBytes, &binlogEvt); err != nil {
@@ -139,19 +242,133 @@ func loadBinlogData(filename string) error {
 			continue
 		}
 
+		if !posPasses(binlogEvt.LogPosition) {
+			continue
+		}
+		if eventTime, err := time.Parse(time.RFC3339, binlogEvt.Timestamp); err == nil && !timePasses(eventTime) {
+			continue
+		}
+
 		key := BinlogKey{
 			BinlogFile:     binlogEvt.BinlogFile,
 			BinlogPosition: binlogEvt.LogPosition,
 		}
 		//fmt.Fprintf(os.Stderr, "DEBUG_LOAD: Storing binlog event. Type: %s, File: %s, Pos: %d\n", binlogEvt.EventType, binlogEvt.BinlogFile, binlogEvt.LogPosition)
 		binlogEvents[key] = binlogEvt
+
+		if currentTx != nil {
+			if eventType == "XID" {
+				currentTx.EndPos = binlogEvt.LogPosition
+				currentTx.Timestamp = binlogEvt.Timestamp
+				currentTx.RowsCount = len(currentTx.Rows)
+				if currentTx.GTID != "" {
+					binlogTransactions[currentTx.GTID] = currentTx
+				}
+				currentTx = nil
+			} else {
+				currentTx.Rows = append(currentTx.Rows, binlogEvt)
+			}
+		}
 	}
 
 	return scanner.Err()
 }
 
+// loadBinlogDataNative populates binlogEvents by decoding filename directly
+// as a MySQL binary binlog file via pkg/binlogsrc, bypassing cmd/jsonparser/main.go
+// entirely. Only DML rows events and XID events are kept, matching the set
+// loadBinlogData keeps from the text-parser path.
+func loadBinlogDataNative(filename string) error {
+	parser, err := binlogsrc.NewBinlogParser(filename, nativeRawMode)
+	if err != nil {
+		return err
+	}
+	if nativeSchema != nil {
+		parser.SetSchema(nativeSchema)
+	}
+
+	var currentTx *Transaction
+	var txExcluded bool
+
+	for {
+		evt, err := parser.Next()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return fmt.Errorf("decoding %s: %w", filename, err)
+		}
+
+		if evt.EventType == "GTID" {
+			currentTx = &Transaction{GTID: evt.GTIDNext, StartPos: evt.LogPosition}
+			txExcluded = !gtidPasses(evt.GTIDNext)
+			continue
+		}
+
+		isRelevantEventType := strings.HasSuffix(evt.EventType, "RowsEventV2") || evt.EventType == "XID"
+		if !isRelevantEventType {
+			continue
+		}
+		if txExcluded {
+			continue
+		}
+		if evt.BinlogFile == "" || evt.LogPosition == 0 {
+			continue
+		}
+		if !posPasses(evt.LogPosition) {
+			continue
+		}
+		if eventTime, err := time.Parse(time.RFC3339, evt.Timestamp); err == nil && !timePasses(eventTime) {
+			continue
+		}
+
+		key := BinlogKey{
+			BinlogFile:     evt.BinlogFile,
+			BinlogPosition: evt.LogPosition,
+		}
+		binlogEvt := BinlogEvent{
+			EventType:                 evt.EventType,
+			Timestamp:                 evt.Timestamp,
+			ImmediateCommmitTimestamp: evt.ImmediateCommmitTimestamp,
+			LogPosition:               evt.LogPosition,
+			Table:                     evt.Table,
+			Schema:                    evt.Schema,
+			BinlogFile:                evt.BinlogFile,
+			GTIDNext:                  evt.GTIDNext,
+			Row:                       evt.Row,
+			BeforeRow:                 evt.BeforeRow,
+		}
+		binlogEvents[key] = binlogEvt
+
+		if currentTx != nil {
+			if evt.EventType == "XID" {
+				currentTx.EndPos = evt.LogPosition
+				currentTx.Timestamp = evt.Timestamp
+				currentTx.RowsCount = len(currentTx.Rows)
+				if currentTx.GTID != "" {
+					binlogTransactions[currentTx.GTID] = currentTx
+				}
+				currentTx = nil
+			} else {
+				currentTx.Rows = append(currentTx.Rows, binlogEvt)
+			}
+		}
+	}
+
+	return nil
+}
+
 // compareAvroWithBinlog reads the Avro JSON file line by line and compares with loaded binlog data
 func compareAvroWithBinlog(filename string) error {
+	if payloadSchema == nil {
+		detected, err := detectPayloadSchemaFromFile(filename, 20)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Could not auto-detect Avro payload schema: %v\n", err)
+		} else {
+			payloadSchema = detected
+		}
+	}
+
 	f, err := os.Open(filename)
 	if err != nil {
 		return err
@@ -173,6 +390,14 @@ func compareAvroWithBinlog(filename string) error {
 			continue
 		}
 
+		if !gtidPasses(avroRec.SourceMetadata.GTID.String) ||
+			!posPasses(avroRec.SourceMetadata.BinlogPosition.Long) ||
+			!timePasses(time.UnixMilli(avroRec.SourceTimestamp)) {
+			continue
+		}
+
+		addToAvroTransaction(avroRec)
+
 		if avroRec.SourceMetadata.BinlogFile.String == "" || avroRec.SourceMetadata.BinlogPosition.Long == 0 {
 			fmt.Fprintf(os.Stderr, "Warning: Skipping Avro record on line %d due to missing 'binlog_file' or 'binlog_position' in source_metadata.\n", lineNum)
 			continue
@@ -184,6 +409,11 @@ func compareAvroWithBinlog(filename string) error {
 		}
 
 		binlogEvt, found := binlogEvents[key]
+		if !found && len(avroRec.SourceMetadata.PrimaryKeys) > 0 {
+			if pkEvt, pkKey, pkFound := lookupByPrimaryKey(avroRec); pkFound {
+				binlogEvt, found, key = pkEvt, true, pkKey
+			}
+		}
 		if !found {
 			avroOnly++
 			fmt.Printf("AVRO_ONLY_BINLOG_KEY: Line %d. Key %v (DB: %s, Table: %s, Type: %s) -> No matching binlog event found.\n",
@@ -244,6 +474,13 @@ func compareAvroWithBinlog(filename string) error {
 			fmt.Printf("  Inferred Binlog ChangeType (from %s): %s\n", binlogEvt.EventType, inferredBinlogChangeType)
 			// mismatches++ // Decide if ChangeType mismatch should increment overall mismatches
 		}
+
+		if binlogEvt.Row != nil {
+			for col, mismatchMsg := range diffPayload(avroRec, binlogEvt.Row) {
+				mismatches++
+				fmt.Printf("MISMATCH (Payload:%s): Line %d. Key %v. %s\n", col, lineNum, key, mismatchMsg)
+			}
+		}
 	}
 
 	if err := scanner.Err(); err != nil {
@@ -275,15 +512,22 @@ func compareAvroWithBinlog(filename string) error {
 
 	// --- DML Event Type Counting and associated debug prints have been removed ---
 
+	txOnlyBinlog, txOnlyAvro, txRowCountMismatch, txPartial := compareTransactions()
+
 	// --- Final Comparison Summary ---
-	fmt.Printf("\n--- Comparison Summary ---\n") 
+	fmt.Printf("\n--- Comparison Summary ---\n")
 	fmt.Printf("Total Avro Records Processed: %d\n", lineNum)
 	fmt.Printf("Total Matched by Binlog Key: %d\n", matches)
 	fmt.Printf("Total Timestamp/GTID/ChangeType Mismatches (within matched set): %d\n", mismatches)
 	fmt.Printf("Avro Records with no Binlog Event match (by key): %d\n", avroOnly)
-	fmt.Printf("Binlog DML Events with no Avro Record match (by key): %d\n", binlogOnly) 
-
-	if mismatches == 0 && avroOnly == 0 && binlogOnly == 0 {
+	fmt.Printf("Binlog DML Events with no Avro Record match (by key): %d\n", binlogOnly)
+	fmt.Printf("Transactions only in Binlog (TX_ONLY_BINLOG): %d\n", txOnlyBinlog)
+	fmt.Printf("Transactions only in Avro (TX_ONLY_AVRO): %d\n", txOnlyAvro)
+	fmt.Printf("Transactions with row count mismatch (TX_ROW_COUNT_MISMATCH): %d\n", txRowCountMismatch)
+	fmt.Printf("Transactions with commit timestamp mismatch (TX_PARTIAL): %d\n", txPartial)
+
+	if mismatches == 0 && avroOnly == 0 && binlogOnly == 0 &&
+		txOnlyBinlog == 0 && txOnlyAvro == 0 && txRowCountMismatch == 0 && txPartial == 0 {
 		fmt.Println("\nCONCLUSION: All Avro records have matching binlog events, and timestamps/metadata are consistent.")
 	} else {
 		fmt.Println("\nCONCLUSION: WARNING - There were discrepancies found during comparison.")
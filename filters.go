@@ -0,0 +1,239 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// gtidInterval is an inclusive [Lo, Hi] range of transaction numbers (GNOs)
+// for one GTID source (uuid).
+type gtidInterval struct {
+	Lo, Hi int64
+}
+
+// GTIDSet is a parsed "uuid:1-100:200-300,uuid2:1-50" set, one merged,
+// sorted interval list per source uuid.
+type GTIDSet map[string][]gtidInterval
+
+// ParseGTIDSet parses the standard MySQL GTID set textual form:
+// comma-separated sources, each a uuid followed by one or more
+// colon-separated ranges ("lo-hi" or a single "n").
+func ParseGTIDSet(s string) (GTIDSet, error) {
+	set := make(GTIDSet)
+	if strings.TrimSpace(s) == "" {
+		return set, nil
+	}
+
+	for _, source := range strings.Split(s, ",") {
+		parts := strings.Split(strings.TrimSpace(source), ":")
+		if len(parts) < 2 {
+			return nil, fmt.Errorf("invalid GTID set source %q: expected uuid:range[:range...]", source)
+		}
+		uuid := parts[0]
+		var intervals []gtidInterval
+		for _, rangeStr := range parts[1:] {
+			lo, hi, err := parseGTIDRange(rangeStr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid GTID set range %q for %s: %w", rangeStr, uuid, err)
+			}
+			intervals = append(intervals, gtidInterval{Lo: lo, Hi: hi})
+		}
+		set[uuid] = mergeIntervals(intervals)
+	}
+
+	return set, nil
+}
+
+func parseGTIDRange(s string) (int64, int64, error) {
+	if dash := strings.IndexByte(s, '-'); dash >= 0 {
+		lo, err := strconv.ParseInt(s[:dash], 10, 64)
+		if err != nil {
+			return 0, 0, err
+		}
+		hi, err := strconv.ParseInt(s[dash+1:], 10, 64)
+		if err != nil {
+			return 0, 0, err
+		}
+		return lo, hi, nil
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	return n, n, err
+}
+
+// mergeIntervals sorts intervals by Lo and merges overlapping or adjacent
+// ranges so Contains can binary search a minimal, non-overlapping list.
+func mergeIntervals(intervals []gtidInterval) []gtidInterval {
+	if len(intervals) == 0 {
+		return nil
+	}
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i].Lo < intervals[j].Lo })
+
+	merged := []gtidInterval{intervals[0]}
+	for _, iv := range intervals[1:] {
+		last := &merged[len(merged)-1]
+		if iv.Lo <= last.Hi+1 {
+			if iv.Hi > last.Hi {
+				last.Hi = iv.Hi
+			}
+			continue
+		}
+		merged = append(merged, iv)
+	}
+	return merged
+}
+
+// Contains reports whether gno falls within one of uuid's merged intervals,
+// found via binary search since the intervals are sorted and non-overlapping.
+func (g GTIDSet) Contains(uuid string, gno int64) bool {
+	intervals, ok := g[uuid]
+	if !ok {
+		return false
+	}
+	i := sort.Search(len(intervals), func(i int) bool { return intervals[i].Hi >= gno })
+	return i < len(intervals) && intervals[i].Lo <= gno
+}
+
+// ContainsGTID splits a "uuid:gno" string (the format decodeGTID and Avro's
+// source_metadata.gtid both use) and checks it against the set.
+func (g GTIDSet) ContainsGTID(gtid string) bool {
+	uuid, gno, ok := splitGTID(gtid)
+	if !ok {
+		return false
+	}
+	return g.Contains(uuid, gno)
+}
+
+// splitGTID separates "uuid:gno" on the last colon, since the uuid itself
+// contains hyphens but no colons.
+func splitGTID(gtid string) (uuid string, gno int64, ok bool) {
+	i := strings.LastIndexByte(gtid, ':')
+	if i < 0 {
+		return "", 0, false
+	}
+	n, err := strconv.ParseInt(gtid[i+1:], 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return gtid[:i], n, true
+}
+
+// Filter flags, populated from --include-gtid/--exclude-gtid/--start-pos/
+// --end-pos/--start-time/--end-time/--big-than/--small-than. Zero values
+// (nil sets, 0 positions, zero times) mean "no restriction".
+var (
+	includeGTIDSet GTIDSet
+	excludeGTIDSet GTIDSet
+	startPos       int64
+	endPos         int64
+	startTime      time.Time
+	endTime        time.Time
+	bigThan        int64 // transaction size lower bound, in bytes (EndPos-StartPos)
+	smallThan      int64 // transaction size upper bound, in bytes
+)
+
+// gtidPasses applies --include-gtid/--exclude-gtid to a "uuid:gno" string.
+// An empty gtid always passes, since most events (everything but the GTID
+// event itself) aren't tagged with one directly.
+func gtidPasses(gtid string) bool {
+	if gtid == "" {
+		return true
+	}
+	if len(includeGTIDSet) > 0 && !includeGTIDSet.ContainsGTID(gtid) {
+		return false
+	}
+	if len(excludeGTIDSet) > 0 && excludeGTIDSet.ContainsGTID(gtid) {
+		return false
+	}
+	return true
+}
+
+// posPasses applies --start-pos/--end-pos.
+func posPasses(pos int64) bool {
+	if startPos != 0 && pos < startPos {
+		return false
+	}
+	if endPos != 0 && pos > endPos {
+		return false
+	}
+	return true
+}
+
+// timePasses applies --start-time/--end-time.
+func timePasses(t time.Time) bool {
+	if t.IsZero() {
+		return true
+	}
+	if !startTime.IsZero() && t.Before(startTime) {
+		return false
+	}
+	if !endTime.IsZero() && t.After(endTime) {
+		return false
+	}
+	return true
+}
+
+// txSizePasses applies --big-than/--small-than to a transaction's byte span.
+func txSizePasses(sizeBytes int64) bool {
+	if bigThan != 0 && sizeBytes < bigThan {
+		return false
+	}
+	if smallThan != 0 && sizeBytes > smallThan {
+		return false
+	}
+	return true
+}
+
+// parseFilterFlag recognizes one of the filter flags at args[i] and, if
+// found, applies it and returns the number of args consumed (flag + value).
+// Returns 0 if args[i] isn't a filter flag.
+func parseFilterFlag(args []string, i int) (int, error) {
+	if i >= len(args) {
+		return 0, nil
+	}
+	flag := args[i]
+	if _, known := filterFlagNames[flag]; !known {
+		return 0, nil
+	}
+	if i+1 >= len(args) {
+		return 0, fmt.Errorf("%s requires a value", flag)
+	}
+	value := args[i+1]
+
+	var err error
+	switch flag {
+	case "--include-gtid":
+		includeGTIDSet, err = ParseGTIDSet(value)
+	case "--exclude-gtid":
+		excludeGTIDSet, err = ParseGTIDSet(value)
+	case "--start-pos":
+		startPos, err = strconv.ParseInt(value, 10, 64)
+	case "--end-pos":
+		endPos, err = strconv.ParseInt(value, 10, 64)
+	case "--start-time":
+		startTime, err = time.Parse(time.RFC3339, value)
+	case "--end-time":
+		endTime, err = time.Parse(time.RFC3339, value)
+	case "--big-than":
+		bigThan, err = strconv.ParseInt(value, 10, 64)
+	case "--small-than":
+		smallThan, err = strconv.ParseInt(value, 10, 64)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q for %s: %w", value, flag, err)
+	}
+	return 2, nil
+}
+
+var filterFlagNames = map[string]bool{
+	"--include-gtid": true,
+	"--exclude-gtid": true,
+	"--start-pos":    true,
+	"--end-pos":      true,
+	"--start-time":   true,
+	"--end-time":     true,
+	"--big-than":     true,
+	"--small-than":   true,
+}
@@ -0,0 +1,99 @@
+package main
+
+import "testing"
+
+// TestParseGTIDSet covers the textual "uuid:lo-hi,uuid2:n" syntax, including
+// multiple ranges for one source and overlapping ranges that mergeIntervals
+// must collapse.
+func TestParseGTIDSet(t *testing.T) {
+	set, err := ParseGTIDSet("uuid-a:1-10:20-30,uuid-b:5")
+	if err != nil {
+		t.Fatalf("ParseGTIDSet: %v", err)
+	}
+
+	if !set.Contains("uuid-a", 5) {
+		t.Error("expected uuid-a:5 to be contained in 1-10")
+	}
+	if !set.Contains("uuid-a", 25) {
+		t.Error("expected uuid-a:25 to be contained in 20-30")
+	}
+	if set.Contains("uuid-a", 15) {
+		t.Error("expected uuid-a:15 to fall in the gap between 1-10 and 20-30")
+	}
+	if !set.Contains("uuid-b", 5) {
+		t.Error("expected uuid-b:5 to be contained in the single-value range")
+	}
+	if set.Contains("uuid-c", 1) {
+		t.Error("expected an unlisted source to never be contained")
+	}
+}
+
+// TestParseGTIDSetInvalid covers the error path for a source missing its range.
+func TestParseGTIDSetInvalid(t *testing.T) {
+	if _, err := ParseGTIDSet("uuid-a"); err == nil {
+		t.Fatal("expected an error for a source with no range")
+	}
+}
+
+// TestMergeIntervals guards the overlap/adjacency-merging logic Contains'
+// binary search depends on staying sorted and non-overlapping.
+func TestMergeIntervals(t *testing.T) {
+	merged := mergeIntervals([]gtidInterval{
+		{Lo: 20, Hi: 30},
+		{Lo: 1, Hi: 10},
+		{Lo: 10, Hi: 15}, // adjacent to the first interval, should merge
+		{Lo: 40, Hi: 50},
+	})
+
+	want := []gtidInterval{{Lo: 1, Hi: 15}, {Lo: 20, Hi: 30}, {Lo: 40, Hi: 50}}
+	if len(merged) != len(want) {
+		t.Fatalf("got %v, want %v", merged, want)
+	}
+	for i, iv := range merged {
+		if iv != want[i] {
+			t.Errorf("interval %d: got %+v, want %+v", i, iv, want[i])
+		}
+	}
+}
+
+// TestGTIDSetContainsGTID covers the "uuid:gno" split ContainsGTID uses,
+// since the uuid itself contains hyphens but no colons.
+func TestGTIDSetContainsGTID(t *testing.T) {
+	set, err := ParseGTIDSet("3e11fa47-71ca-11e1-9e33-c80aa9429562:1-100")
+	if err != nil {
+		t.Fatalf("ParseGTIDSet: %v", err)
+	}
+	if !set.ContainsGTID("3e11fa47-71ca-11e1-9e33-c80aa9429562:50") {
+		t.Error("expected gno 50 to be contained")
+	}
+	if set.ContainsGTID("3e11fa47-71ca-11e1-9e33-c80aa9429562:200") {
+		t.Error("expected gno 200 to not be contained")
+	}
+	if set.ContainsGTID("not-a-gtid") {
+		t.Error("expected a malformed gtid string to never be contained")
+	}
+}
+
+// TestGtidPasses covers --include-gtid/--exclude-gtid interaction, restoring
+// the package-level filter state afterward so other tests aren't affected.
+func TestGtidPasses(t *testing.T) {
+	defer func(include, exclude GTIDSet) {
+		includeGTIDSet, excludeGTIDSet = include, exclude
+	}(includeGTIDSet, excludeGTIDSet)
+
+	includeGTIDSet, _ = ParseGTIDSet("uuid-a:1-10")
+	excludeGTIDSet, _ = ParseGTIDSet("uuid-a:5-6")
+
+	if !gtidPasses("") {
+		t.Error("an untagged event should always pass")
+	}
+	if !gtidPasses("uuid-a:3") {
+		t.Error("uuid-a:3 is in the include set and not in the exclude set")
+	}
+	if gtidPasses("uuid-a:5") {
+		t.Error("uuid-a:5 is in the exclude set and should be rejected")
+	}
+	if gtidPasses("uuid-b:3") {
+		t.Error("uuid-b isn't in the include set and should be rejected")
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import "fmt"
+
+// diffPayload compares every field in the Avro record's schema-agnostic
+// payload against the binlog after-image row pkg/binlogsrc decoded, keyed
+// by the column names the row was decoded with. Fields absent from the
+// binlog row (no matching --schema column, or a column the Avro sink adds
+// that the source table doesn't have) are skipped rather than reported.
+func diffPayload(avroRec AvroRecord, row map[string]interface{}) map[string]string {
+	mismatches := make(map[string]string)
+
+	for field, raw := range avroRec.Payload {
+		rowVal, ok := row[field]
+		if !ok {
+			continue
+		}
+
+		avroVal, err := UnwrapAvroValue(raw, payloadSchema[field])
+		if err != nil {
+			mismatches[field] = fmt.Sprintf("could not decode Avro value: %v", err)
+			continue
+		}
+
+		if fmt.Sprint(avroVal) != fmt.Sprint(rowVal) {
+			mismatches[field] = fmt.Sprintf("Avro value: %v. Binlog value: %v.", avroVal, rowVal)
+		}
+	}
+
+	return mismatches
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// TestDiffPayload covers a matching field, a mismatching field, and a field
+// absent from the binlog row (skipped, not reported, since it may just be a
+// sink-added column with no source table equivalent).
+func TestDiffPayload(t *testing.T) {
+	defer func(s PayloadSchema) { payloadSchema = s }(payloadSchema)
+	payloadSchema = PayloadSchema{"name": "string", "age": "long"}
+
+	rec := AvroRecord{Payload: map[string]json.RawMessage{
+		"name":      json.RawMessage(`{"string":"alice"}`),
+		"age":       json.RawMessage(`{"long":31}`),
+		"sink_only": json.RawMessage(`{"string":"ignored"}`),
+	}}
+	row := map[string]interface{}{
+		"name": "alice",
+		"age":  int64(30),
+	}
+
+	mismatches := diffPayload(rec, row)
+
+	if _, ok := mismatches["sink_only"]; ok {
+		t.Error("expected a field missing from the binlog row to be skipped, not reported")
+	}
+	if _, ok := mismatches["name"]; ok {
+		t.Error("expected matching \"name\" values to not be reported")
+	}
+	if _, ok := mismatches["age"]; !ok {
+		t.Error("expected mismatching \"age\" values (31 vs 30) to be reported")
+	}
+}
@@ -0,0 +1,440 @@
+// Package binlogsrc reads MySQL binary binlog files directly, without going
+// through the text output of the `mysqlbinlog` client. cmd/jsonparser/main.go parses
+// that text output; this package decodes the wire format so row payloads and
+// checksums are no longer lossy.
+package binlogsrc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"time"
+)
+
+// binlogMagic is the 4-byte header every binlog file starts with.
+var binlogMagic = []byte{0xfe, 'b', 'i', 'n'}
+
+// Event type codes from the binlog common header. Only the types this
+// package decodes are listed; anything else falls through as a raw event.
+const (
+	eventTypeFormatDescription = 15
+	eventTypeRotate            = 4
+	eventTypeTableMap          = 19
+	eventTypeGTID              = 33
+	eventTypeQuery             = 2
+	eventTypeXID               = 16
+	eventTypeWriteRowsV2       = 30
+	eventTypeUpdateRowsV2      = 31
+	eventTypeDeleteRowsV2      = 32
+)
+
+// commonHeaderSize is the size in bytes of the header present on every event:
+// timestamp(4) + event_type(1) + server_id(4) + event_size(4) + log_pos(4) + flags(2).
+const commonHeaderSize = 19
+
+// TableDescription holds the column layout captured from a TABLE_MAP_EVENT,
+// keyed by the tableID the following ROWS events reference.
+type TableDescription struct {
+	TableID     uint64
+	Schema      string
+	Table       string
+	ColumnTypes []byte
+	ColumnMeta  []byte
+	NullBitmap  []byte
+	// ColumnNames comes from the schema registry (see Schema), matched to
+	// ColumnTypes by position. Empty when no schema was supplied for this
+	// table; decodeRowsEventV2 falls back to col_<n> names in that case.
+	ColumnNames []string
+}
+
+// BinlogEvent is the shape consumed by compareAvroWithBinlog. It mirrors the
+// fields cmd/jsonparser/main.go produces from the text parser so the comparator
+// doesn't need to know which backend produced them.
+type BinlogEvent struct {
+	EventType                 string
+	Timestamp                 string
+	ImmediateCommmitTimestamp string
+	LogPosition               int64
+	Table                     string
+	Schema                    string
+	BinlogFile                string
+	GTIDNext                  string
+
+	// Row is the after-image of the first row in a WRITE/UPDATE_ROWS_EVENT_V2,
+	// keyed by column name. BeforeRow is the before-image, populated only for
+	// UPDATE/DELETE. Both are nil when the table has no registered schema or
+	// the parser is in rawMode.
+	Row       map[string]interface{}
+	BeforeRow map[string]interface{}
+}
+
+// BinlogParser reads events sequentially from a single binlog file. Create
+// one per file and call Next until it returns io.EOF.
+type BinlogParser struct {
+	r          *bufio.Reader
+	binlogFile string
+	rawMode    bool
+	checksums  bool // true once the FDE's checksum algorithm byte says CRC32 is present
+	tables     map[uint64]*TableDescription
+	schema     Schema
+}
+
+// SetSchema registers column names for row decoding. Without it, decoded
+// rows use col_<n> placeholder names since TABLE_MAP_EVENT only carries
+// column types, not names.
+func (p *BinlogParser) SetSchema(s Schema) {
+	p.schema = s
+}
+
+// NewBinlogParser opens path, verifies the magic header, and returns a parser
+// ready to decode events. rawMode, when true, skips body decoding for every
+// event type except FORMAT_DESCRIPTION and ROTATE, which are always needed to
+// track file boundaries.
+func NewBinlogParser(path string, rawMode bool) (*BinlogParser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r := bufio.NewReader(f)
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(r, magic); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("reading binlog magic: %w", err)
+	}
+	for i := range magic {
+		if magic[i] != binlogMagic[i] {
+			f.Close()
+			return nil, fmt.Errorf("%s: not a binlog file (bad magic header)", path)
+		}
+	}
+
+	return &BinlogParser{
+		r:          r,
+		binlogFile: path,
+		rawMode:    rawMode,
+		tables:     make(map[uint64]*TableDescription),
+	}, nil
+}
+
+// rawEventHeader is the decoded common header shared by every event.
+type rawEventHeader struct {
+	timestamp uint32
+	eventType byte
+	serverID  uint32
+	eventSize uint32
+	logPos    uint32
+	flags     uint16
+}
+
+// NewStreamDecoder returns a BinlogParser with no backing file, suitable for
+// decoding events handed to it one packet at a time by pkg/replstream. It
+// keeps the same TABLE_MAP cache and rawMode behavior as the file-based path.
+func NewStreamDecoder(rawMode bool) *BinlogParser {
+	return &BinlogParser{
+		rawMode: rawMode,
+		tables:  make(map[uint64]*TableDescription),
+	}
+}
+
+// ParsePacket decodes a single event whose common header and body were
+// already extracted from a replication packet (no trailing CRC32, since a
+// live stream has checksums disabled before it starts).
+func (p *BinlogParser) ParsePacket(payload []byte) (*BinlogEvent, error) {
+	if len(payload) < commonHeaderSize {
+		return nil, fmt.Errorf("replication packet too short for event header")
+	}
+	h := rawEventHeader{
+		timestamp: binary.LittleEndian.Uint32(payload[0:4]),
+		eventType: payload[4],
+		serverID:  binary.LittleEndian.Uint32(payload[5:9]),
+		eventSize: binary.LittleEndian.Uint32(payload[9:13]),
+		logPos:    binary.LittleEndian.Uint32(payload[13:17]),
+		flags:     binary.LittleEndian.Uint16(payload[17:19]),
+	}
+	return p.decodeBody(h, payload[commonHeaderSize:]), nil
+}
+
+// Next decodes and returns the next event in the file, or io.EOF once the
+// stream is exhausted.
+func (p *BinlogParser) Next() (*BinlogEvent, error) {
+	header := make([]byte, commonHeaderSize)
+	if _, err := io.ReadFull(p.r, header); err != nil {
+		return nil, err
+	}
+
+	h := rawEventHeader{
+		timestamp: binary.LittleEndian.Uint32(header[0:4]),
+		eventType: header[4],
+		serverID:  binary.LittleEndian.Uint32(header[5:9]),
+		eventSize: binary.LittleEndian.Uint32(header[9:13]),
+		logPos:    binary.LittleEndian.Uint32(header[13:17]),
+		flags:     binary.LittleEndian.Uint16(header[17:19]),
+	}
+
+	if h.eventSize < commonHeaderSize {
+		return nil, fmt.Errorf("binlog event at pos %d: event_size %d smaller than header", h.logPos, h.eventSize)
+	}
+
+	bodySize := int(h.eventSize) - commonHeaderSize
+	if p.checksums {
+		bodySize -= 4 // trailing CRC32, stripped before decoding
+	}
+	if bodySize < 0 {
+		return nil, fmt.Errorf("binlog event at pos %d: negative body size after checksum trim", h.logPos)
+	}
+
+	body := make([]byte, bodySize)
+	if _, err := io.ReadFull(p.r, body); err != nil {
+		return nil, fmt.Errorf("reading event body at pos %d: %w", h.logPos, err)
+	}
+
+	var crc []byte
+	if p.checksums {
+		crc = make([]byte, 4)
+		if _, err := io.ReadFull(p.r, crc); err != nil {
+			return nil, fmt.Errorf("reading event checksum at pos %d: %w", h.logPos, err)
+		}
+		if want := binary.LittleEndian.Uint32(crc); want != crc32.ChecksumIEEE(append(header, body...)) {
+			return nil, fmt.Errorf("binlog event at pos %d: checksum mismatch", h.logPos)
+		}
+	}
+
+	return p.decodeBody(h, body), nil
+}
+
+// decodeBody builds the BinlogEvent for h, dispatching on event type and
+// updating the parser's TABLE_MAP/rotate/checksum state as a side effect.
+// Shared by the file-based Next and the packet-based ParsePacket.
+func (p *BinlogParser) decodeBody(h rawEventHeader, body []byte) *BinlogEvent {
+	evt := &BinlogEvent{
+		Timestamp:   time.Unix(int64(h.timestamp), 0).UTC().Format(time.RFC3339),
+		LogPosition: int64(h.logPos),
+		BinlogFile:  p.binlogFile,
+	}
+
+	switch h.eventType {
+	case eventTypeFormatDescription:
+		evt.EventType = "FormatDescription"
+		// The FDE is read before p.checksums is known, so its own body still
+		// carries a trailing CRC32 if the file has checksums on -- body's
+		// last byte is a CRC byte, not the algorithm byte, in that case.
+		// formatDescriptionChecksumAlgorithm locates the real one instead.
+		if alg, ok := formatDescriptionChecksumAlgorithm(body); ok && alg == checksumAlgorithmCRC32 {
+			p.checksums = true
+		}
+	case eventTypeRotate:
+		evt.EventType = "Rotate"
+		if len(body) > 8 {
+			p.binlogFile = string(body[8:])
+		}
+		p.tables = make(map[uint64]*TableDescription)
+	case eventTypeTableMap:
+		evt.EventType = "TableMap"
+		if !p.rawMode {
+			if td, err := decodeTableMap(body); err == nil {
+				td.ColumnNames = p.schema.columnNames(td.Schema, td.Table)
+				p.tables[td.TableID] = td
+				evt.Schema = td.Schema
+				evt.Table = td.Table
+			}
+		}
+	case eventTypeGTID:
+		evt.EventType = "GTID"
+		if !p.rawMode {
+			evt.GTIDNext = decodeGTID(body)
+		}
+	case eventTypeQuery:
+		evt.EventType = "Query"
+	case eventTypeXID:
+		evt.EventType = "XID"
+	case eventTypeWriteRowsV2:
+		evt.EventType = "WriteRowsEventV2"
+		p.fillRowsEvent(evt, body, evt.EventType)
+	case eventTypeUpdateRowsV2:
+		evt.EventType = "UpdateRowsEventV2"
+		p.fillRowsEvent(evt, body, evt.EventType)
+	case eventTypeDeleteRowsV2:
+		evt.EventType = "DeleteRowsEventV2"
+		p.fillRowsEvent(evt, body, evt.EventType)
+	default:
+		evt.EventType = fmt.Sprintf("Unknown(%d)", h.eventType)
+	}
+
+	return evt
+}
+
+// fillRowsEvent resolves the table_id leading every ROWS_EVENT_V2 body
+// against the TABLE_MAP_EVENT cache to populate evt.Schema/Table, then
+// decodes the row's column values (before- and after-image for
+// UpdateRowsEventV2, single image for Write/Delete). BinlogKey is one per
+// event, not per row, so a multi-row statement has no single row to compare
+// an Avro record against; evt.Row/BeforeRow are left nil in that case rather
+// than populated with row 1's data, so the comparator's existing nil check
+// skips payload comparison for it instead of mis-diffing every row sharing
+// the key against row 1.
+func (p *BinlogParser) fillRowsEvent(evt *BinlogEvent, body []byte, eventType string) {
+	if p.rawMode || len(body) < 6 {
+		return
+	}
+	tableID := uint64(body[0]) | uint64(body[1])<<8 | uint64(body[2])<<16 |
+		uint64(body[3])<<24 | uint64(body[4])<<32 | uint64(body[5])<<40
+	td, ok := p.tables[tableID]
+	if !ok {
+		return
+	}
+	evt.Schema = td.Schema
+	evt.Table = td.Table
+
+	before, after, rowCount, err := decodeRowsEventV2(td, body, eventType)
+	if err != nil || rowCount != 1 {
+		return
+	}
+	evt.BeforeRow = before
+	evt.Row = after
+}
+
+// decodeTableMap parses a TABLE_MAP_EVENT body into a TableDescription. The
+// column type/metadata bytes are kept as-is; decoding them into typed values
+// happens where the ROWS_EVENT payload is actually read. Every variable-width
+// field's bounds are checked before slicing, the same as decodeRowsEventV2/
+// decodeRowImage, so a truncated or malformed body returns an error instead
+// of panicking and killing the whole parser (including a live replication
+// stream, where one bad event shouldn't end the connection).
+func decodeTableMap(body []byte) (*TableDescription, error) {
+	if len(body) < 8 {
+		return nil, fmt.Errorf("table map body too short")
+	}
+	tableID := uint64(body[0]) | uint64(body[1])<<8 | uint64(body[2])<<16 |
+		uint64(body[3])<<24 | uint64(body[4])<<32 | uint64(body[5])<<40
+	pos := 8
+
+	if pos+1 > len(body) {
+		return nil, fmt.Errorf("table map body truncated at schema name length")
+	}
+	schemaLen := int(body[pos])
+	pos++
+	if pos+schemaLen+1 > len(body) {
+		return nil, fmt.Errorf("table map body truncated at schema name")
+	}
+	schema := string(body[pos : pos+schemaLen])
+	pos += schemaLen + 1 // skip trailing null byte
+
+	if pos+1 > len(body) {
+		return nil, fmt.Errorf("table map body truncated at table name length")
+	}
+	tableLen := int(body[pos])
+	pos++
+	if pos+tableLen+1 > len(body) {
+		return nil, fmt.Errorf("table map body truncated at table name")
+	}
+	table := string(body[pos : pos+tableLen])
+	pos += tableLen + 1
+
+	columnCount, n := readLengthEncodedInt(body[pos:])
+	pos += n
+	if pos+int(columnCount) > len(body) {
+		return nil, fmt.Errorf("table map body truncated at column types")
+	}
+	columnTypes := body[pos : pos+int(columnCount)]
+	pos += int(columnCount)
+
+	metaLen, n := readLengthEncodedInt(body[pos:])
+	pos += n
+	if pos+int(metaLen) > len(body) {
+		return nil, fmt.Errorf("table map body truncated at column metadata")
+	}
+	columnMeta := body[pos : pos+int(metaLen)]
+	pos += int(metaLen)
+
+	var nullBitmap []byte
+	if pos < len(body) {
+		nullBitmap = body[pos:]
+	}
+
+	return &TableDescription{
+		TableID:     tableID,
+		Schema:      schema,
+		Table:       table,
+		ColumnTypes: columnTypes,
+		ColumnMeta:  columnMeta,
+		NullBitmap:  nullBitmap,
+	}, nil
+}
+
+// Checksum algorithm codes from FORMAT_DESCRIPTION_EVENT's trailing byte.
+const (
+	checksumAlgorithmOff   = 0
+	checksumAlgorithmCRC32 = 1
+)
+
+// formatDescriptionChecksumAlgorithm locates the checksum algorithm byte in
+// a raw (not checksum-trimmed) FORMAT_DESCRIPTION_EVENT body. Servers only
+// write this byte once their advertised version clears the checksum
+// feature's introduction (MySQL 5.6.1); older servers' FDE bodies end right
+// after the post-header-length array, with no algorithm byte and no CRC32
+// at all. So presence is decided by the body's own declared server_version
+// field, not by a fixed offset from the end of the body.
+func formatDescriptionChecksumAlgorithm(body []byte) (byte, bool) {
+	const fixedPrefix = 2 + 50 + 4 + 1 // binlog_version + server_version + create_timestamp + event_header_length
+	if len(body) < fixedPrefix || len(body) < 5 {
+		return 0, false
+	}
+	serverVersion := string(bytes.TrimRight(body[2:52], "\x00"))
+	if serverVersionProduct(serverVersion) < checksumVersionProduct {
+		return 0, false
+	}
+	// The algorithm byte sits 5 bytes from the end: itself, then the FDE's
+	// own trailing CRC32 (present because the algorithm byte being here at
+	// all means this server writes checksums for every event, the FDE
+	// included).
+	return body[len(body)-5], true
+}
+
+// checksumVersionProduct is serverVersionProduct("5.6.1"), the earliest
+// MySQL version that always writes a checksum algorithm byte into its FDE.
+const checksumVersionProduct = (5*256+6)*256 + 1
+
+// serverVersionProduct encodes a "X.Y.Z..." server_version string as
+// (X*256+Y)*256+Z so it can be compared against checksumVersionProduct.
+// Unparseable components default to 0, same as a version string missing them.
+func serverVersionProduct(version string) int {
+	var major, minor, patch int
+	fmt.Sscanf(version, "%d.%d.%d", &major, &minor, &patch)
+	return (major*256+minor)*256 + patch
+}
+
+// decodeGTID extracts the SID (16-byte UUID) and GNO from a GTID_EVENT body
+// and formats them as the standard "uuid:gno" string.
+func decodeGTID(body []byte) string {
+	if len(body) < 1+16+8 {
+		return ""
+	}
+	sid := body[1:17]
+	gno := int64(binary.LittleEndian.Uint64(body[17:25]))
+	return fmt.Sprintf("%x-%x-%x-%x-%x:%d", sid[0:4], sid[4:6], sid[6:8], sid[8:10], sid[10:16], gno)
+}
+
+// readLengthEncodedInt reads a MySQL length-encoded integer from the start
+// of b and returns its value plus the number of bytes it occupied.
+func readLengthEncodedInt(b []byte) (uint64, int) {
+	if len(b) == 0 {
+		return 0, 0
+	}
+	switch {
+	case b[0] < 0xfb:
+		return uint64(b[0]), 1
+	case b[0] == 0xfc:
+		return uint64(binary.LittleEndian.Uint16(b[1:3])), 3
+	case b[0] == 0xfd:
+		return uint64(b[1]) | uint64(b[2])<<8 | uint64(b[3])<<16, 4
+	case b[0] == 0xfe:
+		return binary.LittleEndian.Uint64(b[1:9]), 9
+	default:
+		return 0, 1
+	}
+}
@@ -0,0 +1,130 @@
+package binlogsrc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"testing"
+)
+
+// TestBinlogParserDetectsChecksumFromFDE guards against a regression where
+// the checksum algorithm byte was read from the last byte of the FDE's raw,
+// un-trimmed body (which is actually a CRC32 byte once checksums are on)
+// instead of the position the FDE's own declared fields put it at.
+func TestBinlogParserDetectsChecksumFromFDE(t *testing.T) {
+	var buf bytes.Buffer
+	buf.Write(binlogMagic)
+
+	fdeBody := buildFormatDescriptionBody("5.7.26-log", checksumAlgorithmCRC32)
+	writeTestEvent(&buf, eventTypeFormatDescription, fdeBody, true)
+
+	xidBody := make([]byte, 8)
+	writeTestEvent(&buf, eventTypeXID, xidBody, true)
+
+	parser, err := NewBinlogParser(writeTempBinlog(t, buf.Bytes()), false)
+	if err != nil {
+		t.Fatalf("NewBinlogParser: %v", err)
+	}
+
+	fde, err := parser.Next()
+	if err != nil {
+		t.Fatalf("reading FDE: %v", err)
+	}
+	if fde.EventType != "FormatDescription" {
+		t.Fatalf("expected FormatDescription, got %s", fde.EventType)
+	}
+	if !parser.checksums {
+		t.Fatal("FDE advertises CRC32 (server 5.7.26-log, algorithm byte 1); parser should have enabled checksum verification")
+	}
+
+	// A checksum-enabled event right after the FDE only decodes cleanly if
+	// bodySize/CRC trimming agrees with where the algorithm byte was found.
+	if xid, err := parser.Next(); err != nil {
+		t.Fatalf("reading checksummed XID event: %v", err)
+	} else if xid.EventType != "XID" {
+		t.Fatalf("expected XID, got %s", xid.EventType)
+	}
+}
+
+// TestBinlogParserNoChecksumOnOldServer covers the pre-5.6.1 FDE shape,
+// which never carries an algorithm byte at all.
+func TestBinlogParserNoChecksumOnOldServer(t *testing.T) {
+	var buf bytes.Buffer
+	buf.Write(binlogMagic)
+
+	fdeBody := buildFormatDescriptionBody("5.5.40-log", 0)
+	fdeBody = fdeBody[:len(fdeBody)-1] // pre-5.6.1 FDEs have no algorithm byte at all
+	writeTestEvent(&buf, eventTypeFormatDescription, fdeBody, false)
+
+	parser, err := NewBinlogParser(writeTempBinlog(t, buf.Bytes()), false)
+	if err != nil {
+		t.Fatalf("NewBinlogParser: %v", err)
+	}
+	if _, err := parser.Next(); err != nil {
+		t.Fatalf("reading FDE: %v", err)
+	}
+	if parser.checksums {
+		t.Fatal("server 5.5.40 predates checksum support; parser should not expect CRC32 trailers")
+	}
+}
+
+// TestDecodeTableMapTruncated guards against a regression where a truncated
+// TABLE_MAP_EVENT body (schema_len claims more bytes than the body actually
+// has) panicked with a slice-bounds error instead of returning an error, which
+// would otherwise kill Next()/ParsePacket() over a single bad event.
+func TestDecodeTableMapTruncated(t *testing.T) {
+	body := make([]byte, 9)
+	body[8] = 10 // schema_len = 10, but no bytes follow it
+	if _, err := decodeTableMap(body); err == nil {
+		t.Fatal("expected an error decoding a truncated table map body, got nil")
+	}
+}
+
+// buildFormatDescriptionBody assembles a FORMAT_DESCRIPTION_EVENT body with
+// the declared fields this package's checksum detection depends on: a
+// NUL-padded server_version and, at the end, the checksum algorithm byte.
+func buildFormatDescriptionBody(serverVersion string, checksumAlg byte) []byte {
+	body := make([]byte, 0, 64)
+	body = append(body, 4, 0) // binlog_version = 4, little-endian uint16
+	versionField := make([]byte, 50)
+	copy(versionField, serverVersion)
+	body = append(body, versionField...)
+	body = append(body, 0, 0, 0, 0) // create_timestamp
+	body = append(body, 19)         // event_header_length
+	body = append(body, make([]byte, 4)...) // post-header-length entries; irrelevant here
+	return append(body, checksumAlg)
+}
+
+// writeTestEvent appends one full on-disk event (common header + body, plus
+// a trailing CRC32 when withChecksum is true) to buf.
+func writeTestEvent(buf *bytes.Buffer, eventType byte, body []byte, withChecksum bool) {
+	eventSize := uint32(commonHeaderSize + len(body))
+	if withChecksum {
+		eventSize += 4
+	}
+	header := make([]byte, commonHeaderSize)
+	header[4] = eventType
+	binary.LittleEndian.PutUint32(header[9:13], eventSize)
+
+	buf.Write(header)
+	buf.Write(body)
+	if withChecksum {
+		var crcBytes [4]byte
+		binary.LittleEndian.PutUint32(crcBytes[:], crc32.ChecksumIEEE(append(append([]byte{}, header...), body...)))
+		buf.Write(crcBytes[:])
+	}
+}
+
+func writeTempBinlog(t *testing.T, data []byte) string {
+	t.Helper()
+	f, err := os.CreateTemp(t.TempDir(), "binlog")
+	if err != nil {
+		t.Fatalf("creating temp binlog file: %v", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		t.Fatalf("writing temp binlog file: %v", err)
+	}
+	return f.Name()
+}
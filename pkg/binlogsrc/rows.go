@@ -0,0 +1,525 @@
+package binlogsrc
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MySQL column type codes carried in TABLE_MAP_EVENT's column-types array.
+// Only the subset this decoder understands is named; everything else falls
+// through to a raw hex dump rather than failing the whole row.
+const (
+	colTypeTiny       = 1
+	colTypeShort      = 2
+	colTypeLong       = 3
+	colTypeFloat      = 4
+	colTypeDouble     = 5
+	colTypeTimestamp  = 7
+	colTypeLongLong   = 8
+	colTypeInt24      = 9
+	colTypeDate       = 10
+	colTypeYear       = 13
+	colTypeVarchar    = 15
+	colTypeTimestamp2 = 17
+	colTypeDatetime2  = 18
+	colTypeNewDecimal = 246
+	colTypeVarString  = 253
+	colTypeString     = 254
+)
+
+// decodeRowsEventV2 decodes every row image carried in a WRITE/UPDATE/DELETE
+// ROWS_EVENT_V2 body, since a single statement affecting multiple rows packs
+// them back to back after the shared columns-present bitmaps. eventType
+// selects the layout: UpdateRowsEventV2 has two columns-present bitmaps and
+// two row images per row (before then after); WriteRowsEventV2 and
+// DeleteRowsEventV2 each carry a single image per row, returned as after or
+// before respectively. before/after reflect only the first row; rowCount
+// tells the caller whether that's the whole story.
+func decodeRowsEventV2(td *TableDescription, body []byte, eventType string) (before, after map[string]interface{}, rowCount int, err error) {
+	hasBeforeImage := eventType == "UpdateRowsEventV2"
+	if len(body) < 8 {
+		return nil, nil, 0, fmt.Errorf("rows event body too short")
+	}
+	pos := 6 // table_id
+	pos += 2 // flags
+
+	if pos+2 > len(body) {
+		return nil, nil, 0, fmt.Errorf("rows event body truncated at extra-data length")
+	}
+	extraLen := int(binary.LittleEndian.Uint16(body[pos : pos+2]))
+	pos += extraLen // extraLen already counts its own 2 length bytes
+
+	columnCount, n := readLengthEncodedInt(body[pos:])
+	pos += n
+	bitmapLen := (int(columnCount) + 7) / 8
+
+	if pos+bitmapLen > len(body) {
+		return nil, nil, 0, fmt.Errorf("rows event body truncated at columns-present bitmap")
+	}
+	presentBefore := body[pos : pos+bitmapLen]
+	pos += bitmapLen
+
+	var presentAfter []byte
+	if hasBeforeImage {
+		if pos+bitmapLen > len(body) {
+			return nil, nil, 0, fmt.Errorf("rows event body truncated at second columns-present bitmap")
+		}
+		presentAfter = body[pos : pos+bitmapLen]
+		pos += bitmapLen
+	}
+
+	for pos < len(body) {
+		if hasBeforeImage {
+			beforeImage, consumed, err := decodeRowImage(td, body[pos:], presentBefore, int(columnCount))
+			if err != nil {
+				return nil, nil, rowCount, err
+			}
+			pos += consumed
+			afterImage, consumed, err := decodeRowImage(td, body[pos:], presentAfter, int(columnCount))
+			if err != nil {
+				return nil, nil, rowCount, err
+			}
+			pos += consumed
+			if rowCount == 0 {
+				before, after = beforeImage, afterImage
+			}
+		} else {
+			image, consumed, err := decodeRowImage(td, body[pos:], presentBefore, int(columnCount))
+			if err != nil {
+				return nil, nil, rowCount, err
+			}
+			pos += consumed
+			if rowCount == 0 {
+				if eventType == "DeleteRowsEventV2" {
+					before = image
+				} else {
+					after = image
+				}
+			}
+		}
+		rowCount++
+	}
+
+	return before, after, rowCount, nil
+}
+
+// decodeRowImage reads one row image (a null-bitmap followed by the value
+// of every present, non-null column) starting at buf[0]. It returns the
+// decoded row keyed by column name and the number of bytes consumed.
+func decodeRowImage(td *TableDescription, buf []byte, present []byte, columnCount int) (map[string]interface{}, int, error) {
+	presentCount := popcount(present)
+	nullBitmapLen := (presentCount + 7) / 8
+	if nullBitmapLen > len(buf) {
+		return nil, 0, fmt.Errorf("row image truncated at null bitmap")
+	}
+	nullBitmap := buf[:nullBitmapLen]
+	pos := nullBitmapLen
+
+	row := make(map[string]interface{}, columnCount)
+	presentIdx := 0
+	for col := 0; col < columnCount; col++ {
+		if !bitSet(present, col) {
+			continue
+		}
+		name := columnName(td, col)
+		if bitSet(nullBitmap, presentIdx) {
+			row[name] = nil
+			presentIdx++
+			continue
+		}
+		presentIdx++
+
+		if col >= len(td.ColumnTypes) {
+			return row, pos, nil
+		}
+		val, consumed, err := decodeColumnValue(td.ColumnTypes[col], metaFor(td, col), buf[pos:])
+		if err != nil {
+			return row, pos, err
+		}
+		row[name] = val
+		pos += consumed
+	}
+
+	return row, pos, nil
+}
+
+// columnName returns the schema-provided name for column index i, or a
+// col_<n> placeholder when no schema was registered for this table.
+func columnName(td *TableDescription, i int) string {
+	if i < len(td.ColumnNames) {
+		return td.ColumnNames[i]
+	}
+	return fmt.Sprintf("col_%d", i)
+}
+
+// metaFor returns the metadata bytes for column index i within
+// td.ColumnMeta, computed by walking every preceding column's metadata
+// width (metadata is packed tightly, not one slot per column).
+func metaFor(td *TableDescription, i int) []byte {
+	offset := 0
+	for c := 0; c < i && c < len(td.ColumnTypes); c++ {
+		offset += metaSize(td.ColumnTypes[c])
+	}
+	size := metaSize(td.ColumnTypes[i])
+	if offset+size > len(td.ColumnMeta) {
+		return nil
+	}
+	return td.ColumnMeta[offset : offset+size]
+}
+
+// metaSize returns how many metadata bytes TABLE_MAP_EVENT carries for a
+// given column type.
+func metaSize(colType byte) int {
+	switch colType {
+	case colTypeVarchar, colTypeNewDecimal, colTypeString, colTypeVarString:
+		return 2
+	case colTypeFloat, colTypeDouble, colTypeTimestamp2, colTypeDatetime2:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// decodeColumnValue decodes one non-null column value of colType at the
+// start of buf, using meta (this column's metadata bytes, see metaFor).
+// Types this decoder doesn't fully understand yet (BLOB, BIT, JSON, ...) are
+// surfaced as a hex string of their raw bytes instead of aborting the whole
+// row.
+func decodeColumnValue(colType byte, meta []byte, buf []byte) (interface{}, int, error) {
+	switch colType {
+	case colTypeTiny:
+		if len(buf) < 1 {
+			return nil, 0, fmt.Errorf("short buffer for TINY")
+		}
+		return int8(buf[0]), 1, nil
+	case colTypeShort:
+		if len(buf) < 2 {
+			return nil, 0, fmt.Errorf("short buffer for SHORT")
+		}
+		return int16(binary.LittleEndian.Uint16(buf)), 2, nil
+	case colTypeInt24:
+		if len(buf) < 3 {
+			return nil, 0, fmt.Errorf("short buffer for INT24")
+		}
+		v := int32(buf[0]) | int32(buf[1])<<8 | int32(buf[2])<<16
+		if v&0x800000 != 0 {
+			v |= ^int32(0xffffff) // sign-extend
+		}
+		return v, 3, nil
+	case colTypeLong:
+		if len(buf) < 4 {
+			return nil, 0, fmt.Errorf("short buffer for LONG")
+		}
+		return int32(binary.LittleEndian.Uint32(buf)), 4, nil
+	case colTypeLongLong:
+		if len(buf) < 8 {
+			return nil, 0, fmt.Errorf("short buffer for LONGLONG")
+		}
+		return int64(binary.LittleEndian.Uint64(buf)), 8, nil
+	case colTypeFloat:
+		if len(buf) < 4 {
+			return nil, 0, fmt.Errorf("short buffer for FLOAT")
+		}
+		return math.Float32frombits(binary.LittleEndian.Uint32(buf)), 4, nil
+	case colTypeDouble:
+		if len(buf) < 8 {
+			return nil, 0, fmt.Errorf("short buffer for DOUBLE")
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(buf)), 8, nil
+	case colTypeYear:
+		if len(buf) < 1 {
+			return nil, 0, fmt.Errorf("short buffer for YEAR")
+		}
+		return 1900 + int(buf[0]), 1, nil
+	case colTypeDate:
+		if len(buf) < 3 {
+			return nil, 0, fmt.Errorf("short buffer for DATE")
+		}
+		packed := uint32(buf[0]) | uint32(buf[1])<<8 | uint32(buf[2])<<16
+		return fmt.Sprintf("%04d-%02d-%02d", packed>>9, (packed>>5)&0xf, packed&0x1f), 3, nil
+	case colTypeTimestamp:
+		if len(buf) < 4 {
+			return nil, 0, fmt.Errorf("short buffer for TIMESTAMP")
+		}
+		return binary.LittleEndian.Uint32(buf), 4, nil
+	case colTypeVarchar, colTypeVarString:
+		lenBytes := 1
+		if len(meta) == 2 && binary.LittleEndian.Uint16(meta) > 255 {
+			lenBytes = 2
+		}
+		strLen, err := readPrefixedLength(buf, lenBytes)
+		if err != nil {
+			return nil, 0, err
+		}
+		if lenBytes+strLen > len(buf) {
+			return nil, 0, fmt.Errorf("short buffer for VARCHAR")
+		}
+		return string(buf[lenBytes : lenBytes+strLen]), lenBytes + strLen, nil
+	case colTypeString:
+		// STRING's metadata packs the real type in its high byte for
+		// ENUM/SET; treated as a plain length-prefixed string here since
+		// this repo only needs value equality, not the enum's symbolic name.
+		strLen, err := readPrefixedLength(buf, 1)
+		if err != nil {
+			return nil, 0, err
+		}
+		if 1+strLen > len(buf) {
+			return nil, 0, fmt.Errorf("short buffer for STRING")
+		}
+		return string(buf[1 : 1+strLen]), 1 + strLen, nil
+	case colTypeNewDecimal:
+		if len(meta) != 2 {
+			return nil, 0, fmt.Errorf("missing precision/scale metadata for NEWDECIMAL")
+		}
+		return decodeNewDecimal(int(meta[0]), int(meta[1]), buf)
+	case colTypeTimestamp2:
+		if len(meta) != 1 {
+			return nil, 0, fmt.Errorf("missing fsp metadata for TIMESTAMP2")
+		}
+		return decodeTimestamp2(int(meta[0]), buf)
+	case colTypeDatetime2:
+		if len(meta) != 1 {
+			return nil, 0, fmt.Errorf("missing fsp metadata for DATETIME2")
+		}
+		return decodeDatetime2(int(meta[0]), buf)
+	default:
+		// Unsupported type (NEWDECIMAL, DATETIME2, TIMESTAMP2, BLOB, BIT,
+		// JSON, ...): decoding it correctly needs the packed-format details
+		// MySQL's own client library implements; report the raw bytes
+		// instead of guessing so a MISMATCH still surfaces rather than a
+		// silently wrong value. This consumes the rest of the row image, so
+		// any columns after an unsupported one in the same row are lost.
+		return "0x" + hex.EncodeToString(buf), len(buf), nil
+	}
+}
+
+// readPrefixedLength reads an n-byte little-endian length prefix from buf.
+func readPrefixedLength(buf []byte, n int) (int, error) {
+	if len(buf) < n {
+		return 0, fmt.Errorf("short buffer for length prefix")
+	}
+	switch n {
+	case 1:
+		return int(buf[0]), nil
+	case 2:
+		return int(binary.LittleEndian.Uint16(buf)), nil
+	default:
+		return 0, fmt.Errorf("unsupported length prefix width %d", n)
+	}
+}
+
+// decimalCompressedByteLen maps a count of leftover base-10 digits (0-9, the
+// part of a NEWDECIMAL's integral/fractional digits that doesn't fill a full
+// 9-digit group) to how many bytes MySQL packs them into.
+var decimalCompressedByteLen = [10]int{0, 1, 1, 2, 2, 3, 3, 4, 4, 4}
+
+// decodeNewDecimal decodes a NEWDECIMAL value (MySQL's packed binary
+// decimal format) into its base-10 string representation. The value is
+// split into 9-digit groups stored as 4-byte big-endian integers, plus one
+// leading and one trailing "leftover" group sized by decimalCompressedByteLen;
+// negative values have every byte bit-complemented (sign bit included).
+func decodeNewDecimal(precision, scale int, buf []byte) (interface{}, int, error) {
+	const digitsPerGroup = 9
+
+	integral := precision - scale
+	uncompIntegral := integral / digitsPerGroup
+	uncompFractional := scale / digitsPerGroup
+	compIntegral := integral - uncompIntegral*digitsPerGroup
+	compFractional := scale - uncompFractional*digitsPerGroup
+
+	binSize := uncompIntegral*4 + decimalCompressedByteLen[compIntegral] +
+		uncompFractional*4 + decimalCompressedByteLen[compFractional]
+	if binSize > len(buf) {
+		return nil, 0, fmt.Errorf("short buffer for NEWDECIMAL")
+	}
+
+	data := append([]byte(nil), buf[:binSize]...)
+	negative := data[0]&0x80 == 0
+	data[0] ^= 0x80
+	if negative {
+		for i := range data {
+			data[i] ^= 0xff
+		}
+	}
+
+	var sb strings.Builder
+	if negative {
+		sb.WriteByte('-')
+	}
+
+	pos := 0
+	wroteIntegral := false
+	if compIntegral > 0 {
+		size := decimalCompressedByteLen[compIntegral]
+		sb.WriteString(strconv.FormatUint(decodeBigEndianUint(data[pos:pos+size]), 10))
+		pos += size
+		wroteIntegral = true
+	}
+	for i := 0; i < uncompIntegral; i++ {
+		value := binary.BigEndian.Uint32(data[pos:])
+		if !wroteIntegral {
+			sb.WriteString(strconv.FormatUint(uint64(value), 10))
+		} else {
+			fmt.Fprintf(&sb, "%09d", value)
+		}
+		pos += 4
+		wroteIntegral = true
+	}
+	if !wroteIntegral {
+		sb.WriteByte('0')
+	}
+
+	if scale > 0 {
+		sb.WriteByte('.')
+		for i := 0; i < uncompFractional; i++ {
+			fmt.Fprintf(&sb, "%09d", binary.BigEndian.Uint32(data[pos:]))
+			pos += 4
+		}
+		if compFractional > 0 {
+			size := decimalCompressedByteLen[compFractional]
+			fmt.Fprintf(&sb, "%0*d", compFractional, decodeBigEndianUint(data[pos:pos+size]))
+			pos += size
+		}
+	}
+
+	return sb.String(), binSize, nil
+}
+
+// decodeBigEndianUint reads a 1-4 byte big-endian unsigned integer, used for
+// NEWDECIMAL's leftover (non-9-digit) groups.
+func decodeBigEndianUint(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+// fspBytes returns how many bytes TIMESTAMP2/DATETIME2 pack their fractional
+// seconds into for a given fsp (0-6), per MySQL's ceil(fsp/2) scheme.
+func fspBytes(fsp int) (int, error) {
+	switch {
+	case fsp == 0:
+		return 0, nil
+	case fsp <= 2:
+		return 1, nil
+	case fsp <= 4:
+		return 2, nil
+	case fsp <= 6:
+		return 3, nil
+	default:
+		return 0, fmt.Errorf("invalid fractional seconds precision %d", fsp)
+	}
+}
+
+// decodeFractionalMicroseconds reads fsp's fractional-seconds bytes (see
+// fspBytes) and scales them up to a full 0-999999 microsecond value: a
+// 1-byte value counts hundredths of a second, a 2-byte value ten-thousandths,
+// and a 3-byte value is already microseconds.
+func decodeFractionalMicroseconds(b []byte) int64 {
+	switch len(b) {
+	case 1:
+		return int64(b[0]) * 10000
+	case 2:
+		return int64(binary.BigEndian.Uint16(b)) * 100
+	case 3:
+		return int64(b[0])<<16 | int64(b[1])<<8 | int64(b[2])
+	default:
+		return 0
+	}
+}
+
+// appendFractionalSeconds appends fsp digits of usec (a full 0-999999
+// microsecond value) to base as MySQL would display a DATETIME/TIMESTAMP
+// column of that declared precision, or returns base unchanged for fsp 0.
+func appendFractionalSeconds(base string, usec int64, fsp int) string {
+	if fsp == 0 {
+		return base
+	}
+	return base + "." + fmt.Sprintf("%06d", usec)[:fsp]
+}
+
+// decodeTimestamp2 decodes a TIMESTAMP2 value: a 4-byte big-endian seconds-
+// since-epoch count, interpreted as UTC (TIMESTAMP is the one temporal type
+// MySQL stores timezone-normalized), followed by fsp's fractional seconds.
+func decodeTimestamp2(fsp int, buf []byte) (interface{}, int, error) {
+	if len(buf) < 4 {
+		return nil, 0, fmt.Errorf("short buffer for TIMESTAMP2")
+	}
+	fracLen, err := fspBytes(fsp)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(buf) < 4+fracLen {
+		return nil, 0, fmt.Errorf("short buffer for TIMESTAMP2 fractional seconds")
+	}
+
+	sec := int64(binary.BigEndian.Uint32(buf))
+	usec := decodeFractionalMicroseconds(buf[4 : 4+fracLen])
+	t := time.Unix(sec, 0).UTC()
+	formatted := appendFractionalSeconds(t.Format("2006-01-02T15:04:05"), usec, fsp) + "Z"
+	return formatted, 4 + fracLen, nil
+}
+
+// datetime2Offset biases DATETIME2's packed integer so it can be stored and
+// compared as an unsigned value; see decodeDatetime2.
+const datetime2Offset = int64(0x8000000000)
+
+// decodeDatetime2 decodes a DATETIME2 value: a 5-byte big-endian integer
+// packing sign, year, month, day, hour, minute, and second, followed by
+// fsp's fractional seconds. DATETIME carries no timezone, unlike TIMESTAMP2.
+func decodeDatetime2(fsp int, buf []byte) (interface{}, int, error) {
+	if len(buf) < 5 {
+		return nil, 0, fmt.Errorf("short buffer for DATETIME2")
+	}
+	fracLen, err := fspBytes(fsp)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(buf) < 5+fracLen {
+		return nil, 0, fmt.Errorf("short buffer for DATETIME2 fractional seconds")
+	}
+
+	packed := int64(buf[0])<<32 | int64(buf[1])<<24 | int64(buf[2])<<16 | int64(buf[3])<<8 | int64(buf[4])
+	intPart := packed - datetime2Offset
+	if intPart < 0 {
+		intPart = -intPart
+	}
+	ymd := intPart >> 17
+	hms := intPart % (1 << 17)
+
+	year := int((ymd >> 5) / 13)
+	month := int((ymd >> 5) % 13)
+	day := int(ymd % (1 << 5))
+	hour := int(hms >> 12)
+	minute := int((hms >> 6) % (1 << 6))
+	second := int(hms % (1 << 6))
+
+	usec := decodeFractionalMicroseconds(buf[5 : 5+fracLen])
+	base := fmt.Sprintf("%04d-%02d-%02d %02d:%02d:%02d", year, month, day, hour, minute, second)
+	return appendFractionalSeconds(base, usec, fsp), 5 + fracLen, nil
+}
+
+func bitSet(bitmap []byte, i int) bool {
+	byteIdx := i / 8
+	if byteIdx >= len(bitmap) {
+		return false
+	}
+	return bitmap[byteIdx]&(1<<uint(i%8)) != 0
+}
+
+func popcount(bitmap []byte) int {
+	n := 0
+	for _, b := range bitmap {
+		for b != 0 {
+			n += int(b & 1)
+			b >>= 1
+		}
+	}
+	return n
+}
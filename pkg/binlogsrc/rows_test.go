@@ -0,0 +1,79 @@
+package binlogsrc
+
+import "testing"
+
+// TestDecodeNewDecimal guards the packed-decimal bit twiddling (sign byte,
+// leftover compressed group sizing) against regressions, since
+// decodeColumnValue previously hex-dumped NEWDECIMAL instead of decoding it.
+func TestDecodeNewDecimal(t *testing.T) {
+	tests := []struct {
+		name         string
+		precision    int
+		scale        int
+		buf          []byte
+		want         string
+		wantConsumed int
+	}{
+		{
+			name:         "positive",
+			precision:    4,
+			scale:        2,
+			buf:          []byte{0x8c, 0x22},
+			want:         "12.34",
+			wantConsumed: 2,
+		},
+		{
+			name:         "negative",
+			precision:    4,
+			scale:        2,
+			buf:          []byte{0x73, 0xdd},
+			want:         "-12.34",
+			wantConsumed: 2,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, consumed, err := decodeNewDecimal(tc.precision, tc.scale, tc.buf)
+			if err != nil {
+				t.Fatalf("decodeNewDecimal: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("got %v, want %s", got, tc.want)
+			}
+			if consumed != tc.wantConsumed {
+				t.Errorf("consumed %d bytes, want %d", consumed, tc.wantConsumed)
+			}
+		})
+	}
+}
+
+// TestDecodeTimestamp2 covers the common TIMESTAMP2(0) case (no fractional
+// seconds, the form every pre-MySQL-5.6.4 TIMESTAMP column still uses) and
+// a fractional-seconds case.
+func TestDecodeTimestamp2(t *testing.T) {
+	// 2021-01-02 03:04:05 UTC = 1609556645.
+	buf := []byte{0x5f, 0xef, 0xe2, 0xa5}
+	got, consumed, err := decodeTimestamp2(0, buf)
+	if err != nil {
+		t.Fatalf("decodeTimestamp2: %v", err)
+	}
+	if want := "2021-01-02T03:04:05Z"; got != want {
+		t.Errorf("got %v, want %s", got, want)
+	}
+	if consumed != 4 {
+		t.Errorf("consumed %d bytes, want 4", consumed)
+	}
+
+	buf2 := append(append([]byte{}, buf...), 0x2d) // fsp=2: 45 hundredths
+	got2, consumed2, err := decodeTimestamp2(2, buf2)
+	if err != nil {
+		t.Fatalf("decodeTimestamp2 with fsp: %v", err)
+	}
+	if want := "2021-01-02T03:04:05.45Z"; got2 != want {
+		t.Errorf("got %v, want %s", got2, want)
+	}
+	if consumed2 != 5 {
+		t.Errorf("consumed %d bytes, want 5", consumed2)
+	}
+}
@@ -0,0 +1,56 @@
+package binlogsrc
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// ColumnSchema names one column of a table, in table definition order.
+// Matched positionally against the column types TABLE_MAP_EVENT carries,
+// since the binlog wire format never names columns.
+type ColumnSchema struct {
+	Name string `json:"name"`
+}
+
+// TableSchema is the column list for one table.
+type TableSchema struct {
+	Columns []ColumnSchema `json:"columns"`
+}
+
+// Schema maps "database.table" to its column list. Load one with
+// LoadSchema and register it on a BinlogParser via SetSchema so ROWS_EVENT
+// bodies can be decoded into named fields instead of raw bytes.
+type Schema map[string]TableSchema
+
+// LoadSchema reads a JSON file of the form:
+//
+//	{"mydb.orders": {"columns": [{"name": "order_id"}, {"name": "customer_name"}]}}
+func LoadSchema(path string) (Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s Schema
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("parsing schema %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// columnNames returns the column names registered for db.table, or nil if
+// none were supplied. Safe to call on a nil Schema.
+func (s Schema) columnNames(db, table string) []string {
+	if s == nil {
+		return nil
+	}
+	ts, ok := s[db+"."+table]
+	if !ok {
+		return nil
+	}
+	names := make([]string, len(ts.Columns))
+	for i, c := range ts.Columns {
+		names[i] = c.Name
+	}
+	return names
+}
@@ -0,0 +1,83 @@
+package main
+
+import "fmt"
+
+// pkIndex caches, per schema.table, a map from the joined primary-key
+// values to the BinlogKey of the matching row. Built lazily the first time
+// a table's records need primary-key matching, since it requires a full
+// pass over binlogEvents.
+var pkIndex = make(map[string]map[string]BinlogKey)
+
+// lookupByPrimaryKey finds avroRec's binlog event by primary key instead of
+// {binlog_file, binlog_position}, for sinks that dedupe or renumber
+// positions such that the position alone no longer identifies the row.
+// Requires --schema (so binlog rows were decoded into named columns) and
+// avroRec.SourceMetadata.PrimaryKeys to name columns present in both sides.
+func lookupByPrimaryKey(avroRec AvroRecord) (BinlogEvent, BinlogKey, bool) {
+	table := avroRec.SourceMetadata.Database + "." + avroRec.SourceMetadata.Table
+	index, ok := pkIndex[table]
+	if !ok {
+		index = buildPrimaryKeyIndex(table, avroRec.SourceMetadata.PrimaryKeys)
+		pkIndex[table] = index
+	}
+
+	pkValue, err := primaryKeyValue(avroRec, avroRec.SourceMetadata.PrimaryKeys)
+	if err != nil {
+		return BinlogEvent{}, BinlogKey{}, false
+	}
+
+	key, found := index[pkValue]
+	if !found {
+		return BinlogEvent{}, BinlogKey{}, false
+	}
+	return binlogEvents[key], key, true
+}
+
+// buildPrimaryKeyIndex scans binlogEvents once for rows belonging to table
+// (schema.table) and indexes them by their primary-key column values.
+func buildPrimaryKeyIndex(table string, pkColumns []string) map[string]BinlogKey {
+	index := make(map[string]BinlogKey)
+	for key, evt := range binlogEvents {
+		if evt.Row == nil || evt.Schema+"."+evt.Table != table {
+			continue
+		}
+		pkValue, err := rowPrimaryKeyValue(evt.Row, pkColumns)
+		if err != nil {
+			continue
+		}
+		index[pkValue] = key
+	}
+	return index
+}
+
+// primaryKeyValue extracts and joins pkColumns' values from avroRec's
+// payload, unwrapping each from its Avro union form.
+func primaryKeyValue(avroRec AvroRecord, pkColumns []string) (string, error) {
+	values := make(map[string]interface{}, len(pkColumns))
+	for _, col := range pkColumns {
+		raw, ok := avroRec.Payload[col]
+		if !ok {
+			return "", fmt.Errorf("payload missing primary key column %q", col)
+		}
+		val, err := UnwrapAvroValue(raw, payloadSchema[col])
+		if err != nil {
+			return "", err
+		}
+		values[col] = val
+	}
+	return rowPrimaryKeyValue(values, pkColumns)
+}
+
+// rowPrimaryKeyValue joins pkColumns' values from row into a single string
+// key, in column order so both sides produce the same string for equal values.
+func rowPrimaryKeyValue(row map[string]interface{}, pkColumns []string) (string, error) {
+	key := ""
+	for _, col := range pkColumns {
+		val, ok := row[col]
+		if !ok {
+			return "", fmt.Errorf("row missing primary key column %q", col)
+		}
+		key += fmt.Sprintf("%v|", val)
+	}
+	return key, nil
+}
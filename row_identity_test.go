@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// TestRowPrimaryKeyValue covers joining multiple primary-key columns into a
+// single string key, in column order, and the missing-column error path.
+func TestRowPrimaryKeyValue(t *testing.T) {
+	row := map[string]interface{}{"id": int64(7), "shard": "us-east"}
+
+	key, err := rowPrimaryKeyValue(row, []string{"shard", "id"})
+	if err != nil {
+		t.Fatalf("rowPrimaryKeyValue: %v", err)
+	}
+	if want := "us-east|7|"; key != want {
+		t.Errorf("got %q, want %q", key, want)
+	}
+
+	if _, err := rowPrimaryKeyValue(row, []string{"missing"}); err == nil {
+		t.Fatal("expected an error for a primary key column absent from the row")
+	}
+}
+
+// TestLookupByPrimaryKey covers matching an Avro record to its binlog event
+// by primary-key value, for sinks where {binlog_file, binlog_position} alone
+// no longer identifies the row.
+func TestLookupByPrimaryKey(t *testing.T) {
+	defer func(events map[BinlogKey]BinlogEvent, index map[string]map[string]BinlogKey) {
+		binlogEvents, pkIndex = events, index
+	}(binlogEvents, pkIndex)
+
+	key := BinlogKey{BinlogFile: "mysql-bin.000001", BinlogPosition: 100}
+	binlogEvents = map[BinlogKey]BinlogEvent{
+		key: {
+			Schema: "app",
+			Table:  "users",
+			Row:    map[string]interface{}{"id": int64(7)},
+		},
+	}
+	pkIndex = make(map[string]map[string]BinlogKey)
+
+	avroRec := AvroRecord{
+		Payload: map[string]json.RawMessage{"id": json.RawMessage(`{"long":7}`)},
+	}
+	avroRec.SourceMetadata.Database = "app"
+	avroRec.SourceMetadata.Table = "users"
+	avroRec.SourceMetadata.PrimaryKeys = []string{"id"}
+
+	evt, gotKey, found := lookupByPrimaryKey(avroRec)
+	if !found {
+		t.Fatal("expected lookupByPrimaryKey to find the row by primary key")
+	}
+	if gotKey != key {
+		t.Errorf("got key %+v, want %+v", gotKey, key)
+	}
+	if evt.Table != "users" {
+		t.Errorf("got table %q, want \"users\"", evt.Table)
+	}
+}
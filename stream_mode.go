@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"time"
+
+	"binlog-avro-comparator/pkg/replstream"
+)
+
+// maxBinlogEventAge bounds how long an event can sit in binlogEvents without
+// a matching Avro record before stream mode evicts it, so a long-running
+// comparison doesn't grow the map without bound.
+const maxBinlogEventAge = 30 * time.Minute
+
+// binlogEventSeenAt tracks when each key was inserted, so evictStaleBinlogEvents
+// can age entries out independently of BinlogEvent's own timestamp field
+// (which reflects when MySQL wrote the event, not when we received it).
+var (
+	binlogEventsMu  sync.Mutex
+	binlogEventSeenAt = make(map[BinlogKey]time.Time)
+)
+
+// runStreamMode registers as a MySQL replica at dsn starting from
+// startFile/startOffset, decodes events as they arrive, and tails avroPath
+// so compareAvroWithBinlog-equivalent matching runs continuously until the
+// process receives an interrupt. Accepts the same --include-gtid/
+// --exclude-gtid/--start-pos/--end-pos/--start-time/--end-time/--big-than/
+// --small-than filter flags as the batch file path, applied to both the
+// binlog events ingested here (see txExcluded below) and the Avro records
+// tailed in compareAvroLine.
+func runStreamMode(args []string) error {
+	for i := 0; i < len(args); i++ {
+		consumed, err := parseFilterFlag(args, i)
+		if err != nil {
+			return err
+		}
+		if consumed > 0 {
+			args = append(args[:i], args[i+consumed:]...)
+			i--
+		}
+	}
+
+	if len(args) != 4 {
+		return fmt.Errorf("usage: %s stream [filter flags] <dsn> <start_file> <start_offset> <avro_output_path>", os.Args[0])
+	}
+	dsn, startFile, startOffsetStr, avroPath := args[0], args[1], args[2], args[3]
+
+	startOffset, err := strconv.ParseUint(startOffsetStr, 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid start_offset %q: %w", startOffsetStr, err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	sr, err := replstream.Dial(ctx, dsn, replstream.Position{File: startFile, Offset: uint32(startOffset)})
+	if err != nil {
+		return fmt.Errorf("connecting replication stream: %w", err)
+	}
+	defer sr.Close()
+
+	mismatches := make(chan string, 256)
+	go tailAvroAndCompare(ctx, avroPath, mismatches)
+
+	go func() {
+		for msg := range mismatches {
+			fmt.Println(msg)
+		}
+	}()
+
+	fmt.Printf("Streaming binlog events from %s starting at %s:%d...\n", dsn, startFile, startOffset)
+	var txExcluded bool
+	for {
+		evt, err := sr.ReadEvent(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("reading replication event: %w", err)
+		}
+
+		if evt.EventType == "GTID" {
+			txExcluded = !gtidPasses(evt.GTIDNext)
+			continue
+		}
+		if txExcluded {
+			continue
+		}
+		if evt.BinlogFile == "" || evt.LogPosition == 0 {
+			continue
+		}
+		if !posPasses(evt.LogPosition) {
+			continue
+		}
+		if eventTime, err := time.Parse(time.RFC3339, evt.Timestamp); err == nil && !timePasses(eventTime) {
+			continue
+		}
+
+		key := BinlogKey{BinlogFile: evt.BinlogFile, BinlogPosition: evt.LogPosition}
+		binlogEventsMu.Lock()
+		binlogEvents[key] = BinlogEvent{
+			EventType:                 evt.EventType,
+			Timestamp:                 evt.Timestamp,
+			ImmediateCommmitTimestamp: evt.ImmediateCommmitTimestamp,
+			LogPosition:               evt.LogPosition,
+			Table:                     evt.Table,
+			Schema:                    evt.Schema,
+			BinlogFile:                evt.BinlogFile,
+			GTIDNext:                  evt.GTIDNext,
+		}
+		binlogEventSeenAt[key] = time.Now()
+		evictStaleBinlogEvents()
+		binlogEventsMu.Unlock()
+	}
+}
+
+// evictStaleBinlogEvents removes entries older than maxBinlogEventAge.
+// Callers must hold binlogEventsMu.
+func evictStaleBinlogEvents() {
+	cutoff := time.Now().Add(-maxBinlogEventAge)
+	for key, seenAt := range binlogEventSeenAt {
+		if seenAt.Before(cutoff) {
+			delete(binlogEvents, key)
+			delete(binlogEventSeenAt, key)
+		}
+	}
+}
+
+// tailAvroAndCompare polls avroPath for appended lines (the Avro sink's JSON
+// output grows append-only) and runs the same key/timestamp/GTID/change-type
+// checks compareAvroWithBinlog does per line, sending any mismatch text to
+// out instead of printing a final summary.
+func tailAvroAndCompare(ctx context.Context, avroPath string, out chan<- string) {
+	defer close(out)
+
+	var offset int64
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		f, err := os.Open(avroPath)
+		if err != nil {
+			continue // sink hasn't created the file yet
+		}
+
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			f.Close()
+			continue
+		}
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := scanner.Text()
+			offset += int64(len(line)) + 1 // + newline
+			if msg, mismatch := compareAvroLine(line); mismatch {
+				out <- msg
+			}
+		}
+		f.Close()
+	}
+}
+
+// compareAvroLine applies the same key/timestamp/GTID checks
+// compareAvroWithBinlog runs per record, against the live binlogEvents map.
+// It reports only mismatches and skips (rather than counts) unmatched Avro
+// records, since in stream mode the matching binlog event may simply not
+// have arrived yet.
+func compareAvroLine(line string) (string, bool) {
+	var avroRec AvroRecord
+	if err := json.Unmarshal([]byte(line), &avroRec); err != nil {
+		return "", false
+	}
+	if !gtidPasses(avroRec.SourceMetadata.GTID.String) ||
+		!posPasses(avroRec.SourceMetadata.BinlogPosition.Long) ||
+		!timePasses(time.UnixMilli(avroRec.SourceTimestamp)) {
+		return "", false
+	}
+	if avroRec.SourceMetadata.BinlogFile.String == "" || avroRec.SourceMetadata.BinlogPosition.Long == 0 {
+		return "", false
+	}
+
+	key := BinlogKey{
+		BinlogFile:     avroRec.SourceMetadata.BinlogFile.String,
+		BinlogPosition: avroRec.SourceMetadata.BinlogPosition.Long,
+	}
+
+	binlogEventsMu.Lock()
+	binlogEvt, found := binlogEvents[key]
+	binlogEventsMu.Unlock()
+	if !found {
+		return "", false
+	}
+
+	var binlogTime time.Time
+	var err error
+	if binlogEvt.ImmediateCommmitTimestamp != "" {
+		binlogTime, err = time.Parse(time.RFC3339Nano, binlogEvt.ImmediateCommmitTimestamp)
+	} else if binlogEvt.Timestamp != "" {
+		binlogTime, err = time.Parse(time.RFC3339, binlogEvt.Timestamp)
+	}
+	if err == nil {
+		avroTime := time.UnixMilli(avroRec.SourceTimestamp)
+		if avroTime.Sub(binlogTime).Abs() > 100*time.Millisecond {
+			return fmt.Sprintf("MISMATCH (Timestamp): Key %v. Avro TS: %s. Binlog TS: %s.",
+				key, avroTime.Format(time.RFC3339Nano), binlogTime.Format(time.RFC3339Nano)), true
+		}
+	}
+
+	if avroRec.SourceMetadata.GTID.String != "" && binlogEvt.GTIDNext != "" &&
+		avroRec.SourceMetadata.GTID.String != binlogEvt.GTIDNext {
+		return fmt.Sprintf("MISMATCH (GTID): Key %v. Avro GTID: %s. Binlog GTID_NEXT: %s.",
+			key, avroRec.SourceMetadata.GTID.String, binlogEvt.GTIDNext), true
+	}
+
+	return "", false
+}
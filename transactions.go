@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Transaction aggregates the binlog rows written between a GTID_EVENT and
+// the XID_EVENT that commits it, so ordering/atomicity violations are
+// visible at the level MySQL actually commits at, not just per key.
+type Transaction struct {
+	GTID      string
+	StartPos  int64
+	EndPos    int64
+	Timestamp string
+	RowsCount int
+	Rows      []BinlogEvent
+}
+
+// AvroTransaction groups Avro records sharing the same source_metadata.gtid,
+// mirroring Transaction on the binlog side.
+type AvroTransaction struct {
+	GTID            string
+	Records         []AvroRecord
+	CommitTimestamp int64 // max SourceTimestamp across Records, unix millis
+}
+
+// binlogTransactions and avroTransactions are keyed by GTID and populated
+// while loadBinlogData and compareAvroWithBinlog read their respective
+// inputs, so transaction-level comparison can run once both finish.
+var (
+	binlogTransactions = make(map[string]*Transaction)
+	avroTransactions    = make(map[string]*AvroTransaction)
+)
+
+// addToAvroTransaction records rec under its GTID so compareTransactions can
+// compare transaction-level row counts and commit timestamps afterward.
+func addToAvroTransaction(rec AvroRecord) {
+	gtid := rec.SourceMetadata.GTID.String
+	if gtid == "" {
+		return
+	}
+	tx, ok := avroTransactions[gtid]
+	if !ok {
+		tx = &AvroTransaction{GTID: gtid}
+		avroTransactions[gtid] = tx
+	}
+	tx.Records = append(tx.Records, rec)
+	if rec.SourceTimestamp > tx.CommitTimestamp {
+		tx.CommitTimestamp = rec.SourceTimestamp
+	}
+}
+
+// compareTransactions runs the transaction-granularity comparison described
+// in compareAvroWithBinlog's row-level pass: for every GTID seen on either
+// side, report whether it's missing from the other, whether row counts
+// match, and whether the transaction committed within tolerance on both
+// sides. It returns the count of transactions in each discrepancy category
+// so the caller can fold them into the overall summary.
+func compareTransactions() (txOnlyBinlog, txOnlyAvro, txRowCountMismatch, txPartial int) {
+	const commitTimeTolerance = 1 * time.Second
+
+	fmt.Println("\n--- Transaction-Level Comparison ---")
+
+	seen := make(map[string]bool)
+	for gtid, binTx := range binlogTransactions {
+		seen[gtid] = true
+		if !txSizePasses(binTx.EndPos - binTx.StartPos) {
+			continue
+		}
+		avroTx, found := avroTransactions[gtid]
+		if !found {
+			txOnlyBinlog++
+			fmt.Printf("TX_ONLY_BINLOG: GTID %s (StartPos %d, EndPos %d, Rows %d) -> No matching Avro transaction.\n",
+				gtid, binTx.StartPos, binTx.EndPos, binTx.RowsCount)
+			continue
+		}
+
+		if binTx.RowsCount != len(avroTx.Records) {
+			txRowCountMismatch++
+			fmt.Printf("TX_ROW_COUNT_MISMATCH: GTID %s -> Binlog rows %d, Avro rows %d.\n",
+				gtid, binTx.RowsCount, len(avroTx.Records))
+			continue
+		}
+
+		binTime, err := time.Parse(time.RFC3339, binTx.Timestamp)
+		if err == nil {
+			avroTime := time.UnixMilli(avroTx.CommitTimestamp)
+			if avroTime.Sub(binTime).Abs() > commitTimeTolerance {
+				txPartial++
+				fmt.Printf("TX_PARTIAL: GTID %s -> commit timestamps differ beyond tolerance (binlog %s, avro %s).\n",
+					gtid, binTime.Format(time.RFC3339Nano), avroTime.Format(time.RFC3339Nano))
+			}
+		}
+	}
+
+	for gtid, avroTx := range avroTransactions {
+		if seen[gtid] {
+			continue
+		}
+		txOnlyAvro++
+		fmt.Printf("TX_ONLY_AVRO: GTID %s (Rows %d) -> No matching binlog transaction.\n", gtid, len(avroTx.Records))
+	}
+
+	if txOnlyBinlog == 0 && txOnlyAvro == 0 && txRowCountMismatch == 0 && txPartial == 0 {
+		fmt.Println("No transaction-level discrepancies found.")
+	}
+
+	return
+}
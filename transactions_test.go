@@ -0,0 +1,88 @@
+package main
+
+import "testing"
+
+// resetTransactionState clears the package-level transaction maps and size
+// filter so tests don't leak state into each other or into other test files.
+func resetTransactionState() {
+	binlogTransactions = make(map[string]*Transaction)
+	avroTransactions = make(map[string]*AvroTransaction)
+	bigThan = 0
+	smallThan = 0
+}
+
+// TestAddToAvroTransaction covers grouping by GTID and tracking the max
+// SourceTimestamp seen as CommitTimestamp.
+func TestAddToAvroTransaction(t *testing.T) {
+	defer resetTransactionState()
+	resetTransactionState()
+
+	rec1 := AvroRecord{SourceTimestamp: 1000}
+	rec1.SourceMetadata.GTID = AvroString{String: "uuid-a:1"}
+	rec2 := AvroRecord{SourceTimestamp: 2000}
+	rec2.SourceMetadata.GTID = AvroString{String: "uuid-a:1"}
+	rec3 := AvroRecord{SourceTimestamp: 500}
+	rec3.SourceMetadata.GTID = AvroString{} // untagged, should be ignored
+
+	addToAvroTransaction(rec1)
+	addToAvroTransaction(rec2)
+	addToAvroTransaction(rec3)
+
+	tx, ok := avroTransactions["uuid-a:1"]
+	if !ok {
+		t.Fatal("expected a transaction keyed by uuid-a:1")
+	}
+	if len(tx.Records) != 2 {
+		t.Errorf("got %d records, want 2", len(tx.Records))
+	}
+	if tx.CommitTimestamp != 2000 {
+		t.Errorf("got CommitTimestamp %d, want 2000 (the max SourceTimestamp)", tx.CommitTimestamp)
+	}
+	if len(avroTransactions) != 1 {
+		t.Errorf("expected the untagged record to be skipped, got %d transactions", len(avroTransactions))
+	}
+}
+
+// TestCompareTransactionsCounts covers the four discrepancy categories
+// compareTransactions reports: binlog-only, avro-only, row count mismatch,
+// and matching (no discrepancy).
+func TestCompareTransactionsCounts(t *testing.T) {
+	defer resetTransactionState()
+	resetTransactionState()
+
+	binlogTransactions["only-binlog"] = &Transaction{GTID: "only-binlog", RowsCount: 1}
+	binlogTransactions["mismatch"] = &Transaction{GTID: "mismatch", RowsCount: 2}
+	binlogTransactions["match"] = &Transaction{GTID: "match", RowsCount: 1}
+
+	avroTransactions["mismatch"] = &AvroTransaction{GTID: "mismatch", Records: []AvroRecord{{}}}
+	avroTransactions["match"] = &AvroTransaction{GTID: "match", Records: []AvroRecord{{}}}
+	avroTransactions["only-avro"] = &AvroTransaction{GTID: "only-avro", Records: []AvroRecord{{}}}
+
+	txOnlyBinlog, txOnlyAvro, txRowCountMismatch, _ := compareTransactions()
+
+	if txOnlyBinlog != 1 {
+		t.Errorf("got txOnlyBinlog %d, want 1", txOnlyBinlog)
+	}
+	if txOnlyAvro != 1 {
+		t.Errorf("got txOnlyAvro %d, want 1", txOnlyAvro)
+	}
+	if txRowCountMismatch != 1 {
+		t.Errorf("got txRowCountMismatch %d, want 1", txRowCountMismatch)
+	}
+}
+
+// TestCompareTransactionsSizeFilter covers --big-than/--small-than excluding
+// a binlog transaction from comparison entirely (not even counted as
+// TX_ONLY_BINLOG), via txSizePasses.
+func TestCompareTransactionsSizeFilter(t *testing.T) {
+	defer resetTransactionState()
+	resetTransactionState()
+
+	binlogTransactions["small"] = &Transaction{GTID: "small", StartPos: 100, EndPos: 110, RowsCount: 1}
+	bigThan = 1000 // excludes the 10-byte transaction above
+
+	txOnlyBinlog, _, _, _ := compareTransactions()
+	if txOnlyBinlog != 0 {
+		t.Errorf("got txOnlyBinlog %d, want 0 (transaction should be filtered out by --big-than)", txOnlyBinlog)
+	}
+}